@@ -43,7 +43,7 @@ func main() {
 	database.Connect()
 
 	// Migrar la Nueva Tabla (AutoMigrate es seguro si los structs están bien definidos)
-	database.Instance.AutoMigrate(&auth.User{}, &betting.Bet{}, &betting.Transaction{}, &market.Match{})
+	database.Instance.AutoMigrate(&auth.User{}, &betting.Bet{}, &betting.BetLeg{}, &betting.CashoutQuote{}, &betting.Transaction{}, &market.Match{}, &market.MarketLine{}, &market.MatchResult{}, &market.PeriodResult{}, &market.OddsSnapshot{}, &market.SyncCursor{}, &market.SyncRun{}, &market.TeamRating{}, &market.RatingHistory{})
 
 	// 3. Inicializar Fiber
 	app := fiber.New(fiber.Config{
@@ -68,8 +68,11 @@ func main() {
 	marketHandler := market.NewHandler(database.Instance)
 
 	// 🔄 MOTOR AUTOMÁTICO (WORKER)
-	// Inicia el proceso en segundo plano para resolver apuestas y simular partidos.
-	worker.StartScheduler(bettingHandler.GetService())
+	// Inicia el proceso en segundo plano para liquidar apuestas con resultados reales
+	// (la simulación de partidos solo se activa con DEMO_MODE=true).
+	worker.StartScheduler(bettingHandler.GetService(), marketHandler.GetService())
+	// Mantiene las cuotas al día sin depender de POST /api/admin/sync.
+	worker.StartMarketSync(marketHandler.GetService())
 
 	// 6. RUTA DE DOCUMENTACIÓN (SWAGGER)
 	app.Get("/swagger/*", fiberSwagger.WrapHandler)
@@ -97,26 +100,42 @@ func main() {
 	// --- Grupo de API (Público / Mixto) ---
 	apiPublic := app.Group("/api")
 	apiPublic.Get("/markets", marketHandler.ListMarketsHandler) // El frontend necesita ver partidos sin login a veces, o puedes protegerlo.
+	apiPublic.Get("/markets/:id/odds-history", marketHandler.OddsHistoryHandler)
+	apiPublic.Get("/ratings", marketHandler.ListRatingsHandler)
+	apiPublic.Get("/ratings/history", marketHandler.RatingHistoryHandler)
 
 	// --- RUTAS PROTEGIDAS (Requieren Token JWT) ---
 	api := app.Group("/api", auth.Protected())
 
 	// Perfil
 	api.Get("/me", authHandler.GetMe)
+	api.Put("/me/staking", authHandler.UpdateStakingHandler)
 
 	// Apuestas
 	api.Post("/bets", bettingHandler.PlaceBet)
+	api.Post("/bets/preview", bettingHandler.PreviewBetHandler)
 	api.Get("/bets", bettingHandler.GetBetsHandler)
-	api.Patch("/bets/:id/resolve", bettingHandler.ResolveBetHandler)
+	api.Patch("/bets/:id/resolve", auth.AdminOnly(), bettingHandler.ResolveBetHandler) // Los usuarios cierran apuestas con /cashout
+	api.Post("/bets/:id/cashout/quote", bettingHandler.CashoutQuoteHandler)
+	api.Post("/bets/:id/cashout", bettingHandler.CashoutHandler)
+	api.Get("/staking/suggest", bettingHandler.StakingSuggestHandler)
 
 	// Finanzas & Stats
 	api.Get("/stats", bettingHandler.GetStatsHandler)
+	api.Get("/stats/bankroll-history", bettingHandler.GetBankrollHistoryHandler)
+	api.Get("/stats/luck", bettingHandler.GetLuckReportHandler)
+	api.Get("/stats/breakdown", bettingHandler.GetBreakdownHandler)
+	api.Get("/stats/simulation", bettingHandler.SimulateBankrollHandler)
+	api.Get("/stats/calibration", bettingHandler.GetCalibrationHandler)
 	api.Get("/transactions", bettingHandler.GetTransactionsHandler)
 
 	// Admin (Protegido)
 	// Eliminamos /sync-ahora público. Usamos este endpoint seguro si necesitamos forzar.
 	api.Post("/admin/sync", marketHandler.SyncMarketsHandler)
-	api.Post("/admin/resolve", bettingHandler.SettleMatchHandler)
+	api.Get("/admin/sync-runs", auth.AdminOnly(), marketHandler.ListSyncRunsHandler)
+	api.Post("/admin/resolve", auth.AdminOnly(), bettingHandler.SettleMatchHandler)
+	api.Post("/admin/results", auth.AdminOnly(), marketHandler.RecordResultHandler)
+	api.Post("/admin/ratings/rebuild", auth.AdminOnly(), marketHandler.RebuildRatingsHandler)
 
 	// 8. Arrancar Servidor
 	port := os.Getenv("PORT")
@@ -39,7 +39,8 @@ type OpenAIResponse struct {
 
 // GenerateTip analiza las estadísticas y devuelve un consejo
 // Recibe los datos crudos del usuario (WinRate, Deporte más rentable, etc)
-func (s *Service) GenerateTip(winRate float64, totalBets int64, topSport string, profit float64) string {
+// fromAI indica si el consejo lo generó el modelo (false = lógica local de respaldo).
+func (s *Service) GenerateTip(winRate float64, totalBets int64, topSport string, profit float64) (tip string, fromAI bool) {
 
 	// 1. Construimos el contexto del usuario
 	prompt := fmt.Sprintf(
@@ -51,7 +52,7 @@ func (s *Service) GenerateTip(winRate float64, totalBets int64, topSport string,
 	if s.apiKey != "" {
 		tip, err := s.callOpenAI(prompt)
 		if err == nil {
-			return "✨ IA: " + tip
+			return "✨ IA: " + tip, true
 		}
 		fmt.Println("Error llamando a OpenAI (usando fallback):", err)
 	}
@@ -60,19 +61,19 @@ func (s *Service) GenerateTip(winRate float64, totalBets int64, topSport string,
 	// Si no hay API Key o falla, usamos lógica condicional avanzada
 	// Esto hace que el sistema parezca inteligente inmediatamente.
 	if totalBets == 0 {
-		return "🤖 Empieza despacio. Analiza las estadísticas de los equipos antes de tu primera apuesta."
+		return "🤖 Empieza despacio. Analiza las estadísticas de los equipos antes de tu primera apuesta.", false
 	}
 	if winRate == 100 {
-		return fmt.Sprintf("🔥 ¡Estás en racha perfecta en %s! Pero cuidado, no te confíes y mantén el stake.", topSport)
+		return fmt.Sprintf("🔥 ¡Estás en racha perfecta en %s! Pero cuidado, no te confíes y mantén el stake.", topSport), false
 	}
 	if profit > 0 {
-		return fmt.Sprintf("📈 Tu estrategia en %s es rentable. Considera aumentar ligeramente el stake si mantienes el ritmo.", topSport)
+		return fmt.Sprintf("📈 Tu estrategia en %s es rentable. Considera aumentar ligeramente el stake si mantienes el ritmo.", topSport), false
 	}
 	if winRate < 40 {
-		return "🛡️ Estás en una mala racha. Tómate un descanso y revisa tus replays."
+		return "🛡️ Estás en una mala racha. Tómate un descanso y revisa tus replays.", false
 	}
 
-	return "📊 Diversifica tus apuestas para minimizar el riesgo."
+	return "📊 Diversifica tus apuestas para minimizar el riesgo.", false
 }
 
 func (s *Service) callOpenAI(prompt string) (string, error) {
@@ -1,11 +1,5 @@
 package analytics
 
-import (
-	"fmt"
-	"math/rand"
-	"time"
-)
-
 type AdvisorResult struct {
 	Message string
 	Level   string
@@ -16,60 +10,52 @@ type StatsInput struct {
 	TotalBets   int
 	TotalProfit float64
 	Bankroll    float64
+	AvgOdds     float64         // Cuota media de las apuestas resueltas
+	AvgCLV      float64         // Closing Line Value medio (%)
+	CLVBets     int64           // Apuestas con precio de cierre
+	Staking     StakingSettings // Configuración de staking del usuario
 }
 
-func GenerateSmartTip(stats StatsInput) AdvisorResult {
-	rand.Seed(time.Now().UnixNano())
-
-	// 1. Fase de Recolección
-	if stats.TotalBets < 5 {
-		return AdvisorResult{
-			Message: "Fase de aprendizaje: Estoy analizando tus primeros movimientos. Necesito 5 registros para activar el motor de rentabilidad.",
-			Level:   "info",
-		}
+// Metrics expone las estadísticas con los nombres que usan las reglas del asesor.
+// El stake sugerido se calcula con el staking del usuario, usando el WinRate histórico
+// como probabilidad y la cuota media.
+func (s StatsInput) Metrics() map[string]float64 {
+	staking := s.Staking
+	if staking.Method == "" {
+		staking = DefaultStakingSettings()
 	}
 
-	// 2. Gestión de Crisis (Profit Negativo)
-	if stats.TotalProfit < 0 {
-		if stats.WinRate > 55 {
-			return AdvisorResult{
-				Message: "⚠️ Paradoja detectada: Ganas muchas apuestas pero pierdes dinero. Estás sobre-apostando a cuotas muy bajas que no compensan el riesgo. ¡Busca más valor!",
-				Level:   "warning",
-			}
-		}
-		return AdvisorResult{
-			Message: "Alerta de varianza: Tu estrategia actual está drenando el bankroll. Te sugiero bajar el Stake al 1% hasta recuperar el 50% de WinRate.",
-			Level:   "warning",
-		}
+	return map[string]float64{
+		MetricWinRate:        s.WinRate,
+		MetricTotalBets:      float64(s.TotalBets),
+		MetricTotalProfit:    s.TotalProfit,
+		MetricBankroll:       s.Bankroll,
+		MetricAvgOdds:        s.AvgOdds,
+		MetricAvgCLV:         s.AvgCLV,
+		MetricCLVBets:        float64(s.CLVBets),
+		MetricSuggestedStake: SuggestStake(s.Bankroll, s.AvgOdds, s.WinRate/100, staking).Stake,
 	}
+}
 
-	// 3. Optimización de Ganancias (Profit Positivo)
-	if stats.TotalProfit > 0 {
-		// Cálculo del Stake Sugerido (Kelly simplificado al 2%)
-		suggestedStake := stats.Bankroll * 0.02
-
-		if stats.WinRate < 40 {
-			return AdvisorResult{
-				Message: fmt.Sprintf("🎯 Estilo Francotirador: Pocos aciertos pero de gran valor. Mantén tu gestión de banca. Tu apuesta ideal hoy es de $%.2f.", suggestedStake),
-				Level:   "success",
-			}
-		}
+// GenerateInsights evalúa las reglas del asesor (ADVISOR_RULES_FILE o las de por defecto)
+// y devuelve todos los insights que se disparan, de mayor a menor prioridad.
+func GenerateInsights(stats StatsInput) []Insight {
+	return defaultEngine().Evaluate(stats)
+}
 
-		// Mensajes aleatorios para éxito para que no sea repetitivo
-		successMessages := []string{
-			fmt.Sprintf("🚀 Sistema Sólido: Estás batiendo al mercado. Mantén el stake en $%.2f para un crecimiento compuesto.", suggestedStake),
-			"🔥 ¡Racha detectada! Tus análisis de E-Sports están siendo precisos. No aumentes el riesgo por euforia.",
-			fmt.Sprintf("💰 Gestión eficiente: Tu curva de profit es saludable. Sigue el plan de $%.2f por unidad.", suggestedStake),
-		}
+// GenerateSmartTip devuelve el insight de mayor prioridad
+func GenerateSmartTip(stats StatsInput) AdvisorResult {
+	return SmartTipFrom(GenerateInsights(stats))
+}
 
+// SmartTipFrom elige el consejo principal de unos insights ya evaluados
+// (el primero, por prioridad), para no evaluar las reglas dos veces
+func SmartTipFrom(insights []Insight) AdvisorResult {
+	if len(insights) == 0 {
 		return AdvisorResult{
-			Message: successMessages[rand.Intn(len(successMessages))],
-			Level:   "success",
+			Message: "Estás en el punto de equilibrio. Es momento de ser más selectivo con las ligas de e-Sports.",
+			Level:   LevelInfo,
 		}
 	}
-
-	return AdvisorResult{
-		Message: "Estás en el punto de equilibrio. Es momento de ser más selectivo con las ligas de e-Sports.",
-		Level:   "info",
-	}
+	return AdvisorResult{Message: insights[0].Message, Level: insights[0].Level}
 }
@@ -0,0 +1,151 @@
+package analytics
+
+import (
+	"math"
+	"time"
+)
+
+// Agrupaciones soportadas para la curva de bankroll
+const (
+	BucketDay  = "day"
+	BucketWeek = "week" // Semanas ISO (de lunes a domingo)
+)
+
+// LedgerEntry es un movimiento del extracto: importe con signo y momento en que ocurrió
+type LedgerEntry struct {
+	At     time.Time
+	Amount float64
+}
+
+// BankrollPoint es el estado del bankroll al cierre de un periodo
+type BankrollPoint struct {
+	Period      time.Time `json:"period"`       // Inicio del día / semana (UTC)
+	Change      float64   `json:"change"`       // Suma de movimientos del periodo
+	Balance     float64   `json:"balance"`      // Saldo acumulado al cierre
+	Peak        float64   `json:"peak"`         // Máximo histórico alcanzado hasta ese cierre
+	DrawdownPct float64   `json:"drawdown_pct"` // Caída desde el máximo al cierre (%)
+	Movements   int       `json:"movements"`
+}
+
+// BankrollHistory es la curva del bankroll con sus métricas de riesgo
+type BankrollHistory struct {
+	Bucket         string  `json:"bucket"`
+	InitialBalance float64 `json:"initial_balance"`
+	CurrentBalance float64 `json:"current_balance"`
+
+	Peak   float64    `json:"peak"`
+	PeakAt *time.Time `json:"peak_at,omitempty"`
+
+	// Drawdown = caída desde el máximo previo, en dinero y en % del máximo
+	MaxDrawdown        float64 `json:"max_drawdown"`
+	MaxDrawdownPct     float64 `json:"max_drawdown_pct"`
+	CurrentDrawdown    float64 `json:"current_drawdown"`
+	CurrentDrawdownPct float64 `json:"current_drawdown_pct"`
+
+	LongestLosingStreak int `json:"longest_losing_streak"`
+	CurrentLosingStreak int `json:"current_losing_streak"`
+
+	Points []BankrollPoint `json:"points"`
+}
+
+// BuildBankrollHistory reconstruye la curva a partir del saldo inicial y los movimientos
+// ordenados cronológicamente. El máximo y los drawdowns se calculan movimiento a movimiento,
+// no solo al cierre de cada periodo, para no esconder caídas intradía.
+func BuildBankrollHistory(initial float64, entries []LedgerEntry, bucket string) BankrollHistory {
+	history := BankrollHistory{
+		Bucket:         bucket,
+		InitialBalance: roundTo2(initial),
+		Peak:           initial,
+		Points:         []BankrollPoint{},
+	}
+
+	balance := initial
+	for _, entry := range entries {
+		balance += entry.Amount
+
+		// 1. Máximo y drawdown
+		if balance > history.Peak {
+			history.Peak = balance
+			at := entry.At
+			history.PeakAt = &at
+		}
+		if drawdown := history.Peak - balance; drawdown > history.MaxDrawdown {
+			history.MaxDrawdown = drawdown
+			history.MaxDrawdownPct = percentOf(drawdown, history.Peak)
+		}
+
+		// 2. Acumular en el periodo que corresponde
+		period := bucketStart(entry.At, bucket)
+		last := len(history.Points) - 1
+		if last < 0 || !history.Points[last].Period.Equal(period) {
+			history.Points = append(history.Points, BankrollPoint{Period: period})
+			last++
+		}
+		point := &history.Points[last]
+		point.Change += entry.Amount
+		point.Balance = balance
+		point.Peak = history.Peak
+		point.DrawdownPct = percentOf(history.Peak-balance, history.Peak)
+		point.Movements++
+	}
+
+	history.CurrentBalance = roundTo2(balance)
+	history.CurrentDrawdown = roundTo2(history.Peak - balance)
+	history.CurrentDrawdownPct = roundTo2(percentOf(history.Peak-balance, history.Peak))
+	history.Peak = roundTo2(history.Peak)
+	history.MaxDrawdown = roundTo2(history.MaxDrawdown)
+	history.MaxDrawdownPct = roundTo2(history.MaxDrawdownPct)
+
+	for i := range history.Points {
+		point := &history.Points[i]
+		point.Change = roundTo2(point.Change)
+		point.Balance = roundTo2(point.Balance)
+		point.Peak = roundTo2(point.Peak)
+		point.DrawdownPct = roundTo2(point.DrawdownPct)
+	}
+
+	return history
+}
+
+// LosingStreaks devuelve la racha perdedora más larga y la actual a partir del
+// resultado de cada apuesta en orden cronológico (profit > 0 gana, < 0 pierde).
+// Las apuestas sin ganancia ni pérdida (anuladas, push) no cortan la racha.
+func LosingStreaks(profits []float64) (longest, current int) {
+	for _, profit := range profits {
+		switch {
+		case profit < 0:
+			current++
+			if current > longest {
+				longest = current
+			}
+		case profit > 0:
+			current = 0
+		}
+	}
+	return longest, current
+}
+
+// bucketStart devuelve el inicio (UTC) del día o de la semana de t
+func bucketStart(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if bucket != BucketWeek {
+		return day
+	}
+	// time.Weekday empieza en domingo (0); las semanas empiezan en lunes
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// percentOf devuelve part como % de total (0 si total no es positivo)
+func percentOf(part, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return part / total * 100
+}
+
+// roundTo2 redondea a céntimos
+func roundTo2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,125 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+)
+
+// Número de tramos de probabilidad del informe de calibración
+const (
+	DefaultCalibrationBuckets = 10
+	MaxCalibrationBuckets     = 20
+)
+
+// logLossEpsilon evita log(0) cuando el usuario declara 0% o 100%
+const logLossEpsilon = 1e-15
+
+// CalibrationSample es una apuesta resuelta con la probabilidad que estimó el usuario
+type CalibrationSample struct {
+	Probability       float64 // Probabilidad estimada por el usuario (0-1)
+	MarketProbability float64 // Probabilidad implícita de la cuota tomada (0 si no se conoce)
+	Won               bool
+}
+
+// CalibrationBucket compara la probabilidad declarada con el acierto real en un tramo
+type CalibrationBucket struct {
+	Range           string  `json:"range"` // Ej: "50-60%"
+	Low             float64 `json:"low"`
+	High            float64 `json:"high"`
+	Bets            int     `json:"bets"`
+	Won             int     `json:"won"`
+	AvgProbability  float64 `json:"avg_probability"`  // Media declarada (%)
+	HitRate         float64 `json:"hit_rate"`         // Acierto real (%)
+	CalibrationDiff float64 `json:"calibration_diff"` // HitRate - AvgProbability (positivo = infravalora sus picks)
+}
+
+// CalibrationReport mide lo bien calibradas que están las probabilidades del usuario.
+// Brier y log loss: cuanto más bajos, mejor. BrierSkill > 0 significa que el usuario
+// predice mejor que las cuotas que tomó.
+type CalibrationReport struct {
+	Bets        int                 `json:"bets"`
+	BrierScore  float64             `json:"brier_score"`
+	LogLoss     float64             `json:"log_loss"`
+	MarketBrier float64             `json:"market_brier"` // Brier de la probabilidad implícita de las cuotas
+	BrierSkill  float64             `json:"brier_skill"`  // 1 - Brier / MarketBrier
+	Buckets     []CalibrationBucket `json:"buckets"`
+}
+
+// BuildCalibrationReport agrupa las apuestas en 'buckets' tramos de igual anchura según
+// la probabilidad declarada y calcula Brier score y log loss. Los tramos vacíos se omiten.
+func BuildCalibrationReport(samples []CalibrationSample, buckets int) CalibrationReport {
+	if buckets <= 0 || buckets > MaxCalibrationBuckets {
+		buckets = DefaultCalibrationBuckets
+	}
+
+	report := CalibrationReport{Bets: len(samples), Buckets: []CalibrationBucket{}}
+	if len(samples) == 0 {
+		return report
+	}
+
+	// 1. Acumular por tramo
+	width := 1.0 / float64(buckets)
+	grouped := make([]CalibrationBucket, buckets)
+	probabilitySums := make([]float64, buckets)
+
+	var brier, logLoss, marketBrier float64
+	marketSamples := 0
+	for _, sample := range samples {
+		outcome := 0.0
+		if sample.Won {
+			outcome = 1
+		}
+
+		brier += math.Pow(sample.Probability-outcome, 2)
+		p := math.Min(math.Max(sample.Probability, logLossEpsilon), 1-logLossEpsilon)
+		logLoss -= outcome*math.Log(p) + (1-outcome)*math.Log(1-p)
+
+		if sample.MarketProbability > 0 {
+			marketBrier += math.Pow(sample.MarketProbability-outcome, 2)
+			marketSamples++
+		}
+
+		// El épsilon evita que 0.3 / 0.1 = 2.999... caiga en el tramo anterior;
+		// la probabilidad 1 cae en el último tramo
+		index := int(sample.Probability/width + 1e-9)
+		if index >= buckets {
+			index = buckets - 1
+		}
+		grouped[index].Bets++
+		if sample.Won {
+			grouped[index].Won++
+		}
+		probabilitySums[index] += sample.Probability
+	}
+
+	n := float64(len(samples))
+	report.BrierScore = roundTo4(brier / n)
+	report.LogLoss = roundTo4(logLoss / n)
+	if marketSamples > 0 {
+		report.MarketBrier = roundTo4(marketBrier / float64(marketSamples))
+		if report.MarketBrier > 0 {
+			report.BrierSkill = roundTo4(1 - report.BrierScore/report.MarketBrier)
+		}
+	}
+
+	// 2. Métricas de cada tramo
+	for i, bucket := range grouped {
+		if bucket.Bets == 0 {
+			continue
+		}
+		bucket.Low = roundTo2(float64(i) * width * 100)
+		bucket.High = roundTo2(float64(i+1) * width * 100)
+		bucket.Range = fmt.Sprintf("%g-%g%%", bucket.Low, bucket.High)
+		bucket.AvgProbability = roundTo2(probabilitySums[i] / float64(bucket.Bets) * 100)
+		bucket.HitRate = roundTo2(float64(bucket.Won) / float64(bucket.Bets) * 100)
+		bucket.CalibrationDiff = roundTo2(bucket.HitRate - bucket.AvgProbability)
+		report.Buckets = append(report.Buckets, bucket)
+	}
+
+	return report
+}
+
+// roundTo4 redondea métricas pequeñas como Brier o log loss
+func roundTo4(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
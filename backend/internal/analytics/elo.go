@@ -0,0 +1,31 @@
+package analytics
+
+import "math"
+
+// Parámetros por defecto del sistema Elo
+const (
+	DefaultEloRating = 1500.0 // Rating inicial de un equipo sin partidos
+	DefaultEloK      = 32.0   // Cuánto se mueve el rating tras cada partido
+)
+
+// Resultado de un partido desde el punto de vista del local
+const (
+	EloWin  = 1.0
+	EloDraw = 0.5
+	EloLoss = 0.0
+)
+
+// EloExpected devuelve la puntuación esperada (probabilidad de victoria en un mercado
+// a dos vías) de un equipo con 'rating' frente a uno con 'opponent':
+//
+//	E = 1 / (1 + 10^((opponent - rating) / 400))
+func EloExpected(rating, opponent float64) float64 {
+	return 1 / (1 + math.Pow(10, (opponent-rating)/400))
+}
+
+// EloUpdate devuelve los nuevos ratings de local y visitante tras un partido.
+// homeScore es EloWin, EloDraw o EloLoss; lo que gana uno lo pierde el otro.
+func EloUpdate(home, away, homeScore, k float64) (newHome, newAway float64) {
+	delta := k * (homeScore - EloExpected(home, away))
+	return home + delta, away - delta
+}
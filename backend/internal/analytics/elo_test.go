@@ -0,0 +1,61 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEloExpected(t *testing.T) {
+	tests := []struct {
+		name             string
+		rating, opponent float64
+		want             float64
+	}{
+		{"ratings iguales", 1500, 1500, 0.5},
+		{"400 puntos de ventaja", 1900, 1500, 10.0 / 11},
+		{"400 puntos de desventaja", 1500, 1900, 1.0 / 11},
+		{"200 puntos de ventaja", 1700, 1500, 1 / (1 + math.Pow(10, -0.5))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EloExpected(tt.rating, tt.opponent)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("EloExpected(%v, %v) = %v, se esperaba %v", tt.rating, tt.opponent, got, tt.want)
+			}
+			// Las probabilidades de ambos equipos suman 1
+			if sum := got + EloExpected(tt.opponent, tt.rating); math.Abs(sum-1) > 1e-9 {
+				t.Errorf("las probabilidades suman %v", sum)
+			}
+		})
+	}
+}
+
+func TestEloUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		home      float64
+		away      float64
+		homeScore float64
+		wantHome  float64
+	}{
+		{"empate entre iguales no cambia nada", 1500, 1500, EloDraw, 1500},
+		{"victoria entre iguales suma k/2", 1500, 1500, EloWin, 1516},
+		{"derrota entre iguales resta k/2", 1500, 1500, EloLoss, 1484},
+		{"el favorito gana menos por ganar", 1900, 1500, EloWin, 1900 + DefaultEloK/11},
+		{"el favorito pierde puntos si empata", 1900, 1500, EloDraw, 1900 + DefaultEloK*(0.5-10.0/11)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newHome, newAway := EloUpdate(tt.home, tt.away, tt.homeScore, DefaultEloK)
+			if math.Abs(newHome-tt.wantHome) > 1e-9 {
+				t.Errorf("nuevo rating local = %v, se esperaba %v", newHome, tt.wantHome)
+			}
+			// Suma cero: lo que gana el local lo pierde el visitante
+			if diff := (newHome + newAway) - (tt.home + tt.away); math.Abs(diff) > 1e-9 {
+				t.Errorf("la actualización no es de suma cero: diferencia %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package analytics
+
+// KellyFraction devuelve la fracción del bankroll que maximiza el crecimiento a largo plazo
+// para una apuesta con probabilidad real 'probability' y cuota decimal 'odds':
+//
+//	f* = (p * cuota - 1) / (cuota - 1)
+//
+// Devuelve 0 si la apuesta no tiene valor esperado positivo.
+func KellyFraction(probability, odds float64) float64 {
+	if odds <= 1 || probability <= 0 {
+		return 0
+	}
+	fraction := (probability*odds - 1) / (odds - 1)
+	if fraction < 0 {
+		return 0
+	}
+	return fraction
+}
+
+// ExpectedValue es la ganancia esperada por unidad apostada (0.05 = +5%)
+func ExpectedValue(probability, odds float64) float64 {
+	return probability*odds - 1
+}
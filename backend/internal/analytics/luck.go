@@ -0,0 +1,120 @@
+package analytics
+
+import "math"
+
+// zScore95 es el valor crítico de la normal para un intervalo de confianza del 95%
+const zScore95 = 1.96
+
+// Veredictos del informe de suerte
+const (
+	LuckVerdictLucky    = "lucky"           // Resultados por encima de lo esperable por varianza
+	LuckVerdictUnlucky  = "unlucky"         // Resultados por debajo de lo esperable por varianza
+	LuckVerdictVariance = "within_variance" // La diferencia se explica por el azar
+	LuckVerdictNoData   = "insufficient_data"
+)
+
+// LuckSample es una apuesta resuelta (ganada o perdida) para el informe de suerte
+type LuckSample struct {
+	Stake       float64
+	Odds        float64 // Cuota tomada
+	Probability float64 // Probabilidad de referencia (ver MarginFree)
+	MarginFree  bool    // true si Probability es la justa (sin margen) del cierre; si no, 1 / cuota
+	Won         bool
+}
+
+// LuckMetric compara un resultado real con su valor esperado
+type LuckMetric struct {
+	Expected   float64 `json:"expected"`
+	Actual     float64 `json:"actual"`
+	Difference float64 `json:"difference"` // Actual - Expected
+	StdDev     float64 `json:"std_dev"`
+	ZScore     float64 `json:"z_score"`
+	PValue     float64 `json:"p_value"` // Bilateral
+	CILow      float64 `json:"ci_low"`  // Intervalo del 95% alrededor del valor esperado
+	CIHigh     float64 `json:"ci_high"`
+	Verdict    string  `json:"verdict"` // lucky, unlucky, within_variance
+}
+
+// LuckReport separa suerte y habilidad: cuántas apuestas y cuánto dinero "debería"
+// haber ganado el usuario según las probabilidades del mercado frente a lo que ganó.
+//
+// Las apuestas sin cierre completo del mercado usan 1 / cuota, que incluye el margen de la
+// casa: su acierto esperado sale inflado y empuja el veredicto hacia "unlucky".
+// MarginFreeBets indica cuántas apuestas se midieron sin ese sesgo.
+type LuckReport struct {
+	Bets           int        `json:"bets"`
+	MarginFreeBets int        `json:"margin_free_bets"`
+	Wins           LuckMetric `json:"wins"`
+	Profit         LuckMetric `json:"profit"`
+	Verdict        string     `json:"verdict"` // Según los aciertos
+}
+
+// BuildLuckReport calcula aciertos y profit esperados sumando las probabilidades de referencia.
+// Cada apuesta es una Bernoulli: la varianza de los aciertos es Σ p(1-p) y la del
+// profit Σ p(1-p)·(stake·cuota)². Con |z| > 1.96 la racha es estadísticamente significativa.
+func BuildLuckReport(samples []LuckSample) LuckReport {
+	report := LuckReport{Bets: len(samples), Verdict: LuckVerdictNoData}
+	if len(samples) == 0 {
+		report.Wins.Verdict = LuckVerdictNoData
+		report.Profit.Verdict = LuckVerdictNoData
+		return report
+	}
+
+	var expectedWins, winsVariance, actualWins float64
+	var expectedProfit, profitVariance, actualProfit float64
+	for _, sample := range samples {
+		p := sample.Probability
+		variance := p * (1 - p)
+		if sample.MarginFree {
+			report.MarginFreeBets++
+		}
+
+		expectedWins += p
+		winsVariance += variance
+
+		// Ganancia si acierta: stake·(cuota-1); si falla: -stake
+		expectedProfit += sample.Stake * (p*sample.Odds - 1)
+		profitVariance += variance * math.Pow(sample.Stake*sample.Odds, 2)
+
+		if sample.Won {
+			actualWins++
+			actualProfit += sample.Stake * (sample.Odds - 1)
+		} else {
+			actualProfit -= sample.Stake
+		}
+	}
+
+	report.Wins = luckMetric(expectedWins, actualWins, math.Sqrt(winsVariance))
+	report.Profit = luckMetric(expectedProfit, actualProfit, math.Sqrt(profitVariance))
+	report.Verdict = report.Wins.Verdict
+	return report
+}
+
+// luckMetric arma la comparación real vs. esperado con su z-score e intervalo
+func luckMetric(expected, actual, stdDev float64) LuckMetric {
+	metric := LuckMetric{
+		Expected:   roundTo2(expected),
+		Actual:     roundTo2(actual),
+		Difference: roundTo2(actual - expected),
+		StdDev:     roundTo2(stdDev),
+		CILow:      roundTo2(expected - zScore95*stdDev),
+		CIHigh:     roundTo2(expected + zScore95*stdDev),
+		PValue:     1,
+		Verdict:    LuckVerdictVariance,
+	}
+	if stdDev == 0 {
+		return metric
+	}
+
+	z := (actual - expected) / stdDev
+	metric.ZScore = roundTo2(z)
+	metric.PValue = math.Round(math.Erfc(math.Abs(z)/math.Sqrt2)*10000) / 10000
+
+	switch {
+	case z > zScore95:
+		metric.Verdict = LuckVerdictLucky
+	case z < -zScore95:
+		metric.Verdict = LuckVerdictUnlucky
+	}
+	return metric
+}
@@ -0,0 +1,90 @@
+package analytics
+
+import "math"
+
+// Métodos para quitar el margen (vig) de la casa a un mercado
+const (
+	VigMethodMultiplicative = "multiplicative"
+	VigMethodPower          = "power"
+)
+
+// ImpliedProbability es la probabilidad que implica una cuota decimal (con margen incluido)
+func ImpliedProbability(odds float64) float64 {
+	if odds <= 0 {
+		return 0
+	}
+	return 1 / odds
+}
+
+// Overround devuelve el margen de la casa de un mercado completo:
+// la suma de probabilidades implícitas menos 1 (0.05 = 5%).
+func Overround(odds []float64) float64 {
+	total := 0.0
+	for _, o := range odds {
+		total += ImpliedProbability(o)
+	}
+	return total - 1
+}
+
+// FairProbabilities reparte el margen y devuelve las probabilidades justas (suman 1).
+//   - multiplicative: divide cada probabilidad implícita por la suma (reparto proporcional).
+//   - power: busca k tal que la suma de p^k sea 1; quita proporcionalmente más margen
+//     a los underdogs, corrigiendo el sesgo favorito-underdog.
+//
+// Devuelve nil si alguna cuota no es válida (<= 1).
+func FairProbabilities(odds []float64, method string) []float64 {
+	implied := make([]float64, len(odds))
+	for i, o := range odds {
+		if o <= 1 {
+			return nil
+		}
+		implied[i] = ImpliedProbability(o)
+	}
+
+	if method == VigMethodPower {
+		return powerProbabilities(implied)
+	}
+
+	total := 0.0
+	for _, p := range implied {
+		total += p
+	}
+	fair := make([]float64, len(implied))
+	for i, p := range implied {
+		fair[i] = p / total
+	}
+	return fair
+}
+
+// powerProbabilities resuelve sum(p_i^k) = 1 por bisección.
+// Con margen positivo k > 1; si el mercado paga más de lo justo, k < 1.
+func powerProbabilities(implied []float64) []float64 {
+	sumPow := func(k float64) float64 {
+		total := 0.0
+		for _, p := range implied {
+			total += math.Pow(p, k)
+		}
+		return total
+	}
+
+	// sum(p^k) decrece con k: buscamos en [low, high] hasta encerrar la raíz
+	low, high := 0.01, 1.0
+	for sumPow(high) > 1 && high < 100 {
+		high *= 2
+	}
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		if sumPow(mid) > 1 {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	k := (low + high) / 2
+	fair := make([]float64, len(implied))
+	for i, p := range implied {
+		fair[i] = math.Pow(p, k)
+	}
+	return fair
+}
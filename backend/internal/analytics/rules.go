@@ -0,0 +1,309 @@
+package analytics
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// ErrInvalidRules indica un fichero de reglas mal formado
+var ErrInvalidRules = errors.New("reglas del asesor inválidas")
+
+// defaultRulesJSON son las reglas que se usan si no se configura ADVISOR_RULES_FILE
+//
+//go:embed advisor_rules.json
+var defaultRulesJSON []byte
+
+// Niveles de un insight (el frontend los usa para el estilo)
+const (
+	LevelInfo    = "info"
+	LevelSuccess = "success"
+	LevelWarning = "warning"
+	LevelDanger  = "danger"
+)
+
+// Métricas que pueden usar las condiciones y las plantillas de las reglas
+const (
+	MetricWinRate        = "win_rate"
+	MetricTotalBets      = "total_bets"
+	MetricTotalProfit    = "total_profit"
+	MetricBankroll       = "bankroll"
+	MetricAvgOdds        = "avg_odds"
+	MetricAvgCLV         = "avg_clv"
+	MetricCLVBets        = "clv_bets"
+	MetricSuggestedStake = "suggested_stake"
+)
+
+var knownMetrics = map[string]bool{
+	MetricWinRate: true, MetricTotalBets: true, MetricTotalProfit: true, MetricBankroll: true,
+	MetricAvgOdds: true, MetricAvgCLV: true, MetricCLVBets: true, MetricSuggestedStake: true,
+}
+
+var knownLevels = map[string]bool{LevelInfo: true, LevelSuccess: true, LevelWarning: true, LevelDanger: true}
+
+// templateFuncs son los formatos disponibles en los mensajes: {{money .suggested_stake}}, {{pct .win_rate}}
+var templateFuncs = template.FuncMap{
+	"money": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"pct":   func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+	"int":   func(v float64) string { return fmt.Sprintf("%.0f", v) },
+}
+
+// Condition compara una métrica con un valor: {"metric": "win_rate", "op": ">", "value": 55}
+type Condition struct {
+	Metric string  `json:"metric"`
+	Op     string  `json:"op"` // <, <=, >, >=, ==, !=
+	Value  float64 `json:"value"`
+}
+
+// Rule es una regla del asesor. Se dispara cuando se cumplen todas sus condiciones.
+type Rule struct {
+	Code     string      `json:"code"`
+	Title    string      `json:"title"`
+	Priority int         `json:"priority"` // Mayor prioridad = se evalúa y se muestra antes
+	Level    string      `json:"level"`
+	When     []Condition `json:"when"`
+	Message  string      `json:"message"` // Plantilla text/template sobre las métricas
+	Stop     bool        `json:"stop"`    // Si se dispara, no se evalúan las reglas siguientes
+
+	message *template.Template
+}
+
+// RuleSet es el formato del fichero de reglas
+type RuleSet struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Orígenes de un insight
+const (
+	InsightSourceRules = "rules" // Motor de reglas del asesor
+	InsightSourceAI    = "ai"    // Generado por el modelo de lenguaje
+)
+
+// Insight es un consejo estructurado: el resultado de una regla disparada o de la IA
+type Insight struct {
+	Code     string             `json:"code"`
+	Level    string             `json:"level"`
+	Title    string             `json:"title"`
+	Message  string             `json:"message"`
+	Priority int                `json:"priority"`
+	Metrics  map[string]float64 `json:"metrics"` // Métricas que lo justifican
+	Source   string             `json:"source"`  // rules o ai
+}
+
+// RuleEngine evalúa las reglas del asesor. Si se creó desde un fichero, lo vuelve
+// a leer cuando cambia su fecha de modificación (recarga en caliente sin redeploy).
+type RuleEngine struct {
+	mu      sync.RWMutex
+	path    string
+	modTime time.Time
+	rules   []Rule
+}
+
+// NewRuleEngine crea el motor con las reglas de 'path', o con las reglas por defecto si path está vacío
+func NewRuleEngine(path string) (*RuleEngine, error) {
+	engine := &RuleEngine{path: path}
+	if path == "" {
+		rules, err := ParseRules(defaultRulesJSON)
+		if err != nil {
+			return nil, err
+		}
+		engine.rules = rules
+		return engine, nil
+	}
+
+	if err := engine.reload(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// defaultEngine es el motor que usa GenerateSmartTip (ADVISOR_RULES_FILE o reglas embebidas)
+var defaultEngine = sync.OnceValue(func() *RuleEngine {
+	if path := os.Getenv("ADVISOR_RULES_FILE"); path != "" {
+		engine, err := NewRuleEngine(path)
+		if err == nil {
+			return engine
+		}
+		log.Printf("⚠️  [ADVISOR] No se pudieron cargar las reglas de %s (usando las de por defecto): %v", path, err)
+	}
+	engine, err := NewRuleEngine("")
+	if err != nil {
+		// Las reglas embebidas se validan al compilar la imagen; si fallan es un error de programación
+		panic(err)
+	}
+	return engine
+})
+
+// ParseRules lee, valida y ordena un conjunto de reglas en JSON.
+// El orden es determinista: prioridad descendente y, a igual prioridad, por código.
+func ParseRules(data []byte) ([]Rule, error) {
+	var set RuleSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRules, err)
+	}
+
+	seen := make(map[string]bool, len(set.Rules))
+	for i := range set.Rules {
+		rule := &set.Rules[i]
+		if rule.Code == "" || seen[rule.Code] {
+			return nil, fmt.Errorf("%w: cada regla necesita un código único (regla %d)", ErrInvalidRules, i)
+		}
+		seen[rule.Code] = true
+
+		if !knownLevels[rule.Level] {
+			return nil, fmt.Errorf("%w: nivel '%s' desconocido en '%s'", ErrInvalidRules, rule.Level, rule.Code)
+		}
+		for _, condition := range rule.When {
+			if !knownMetrics[condition.Metric] {
+				return nil, fmt.Errorf("%w: métrica '%s' desconocida en '%s'", ErrInvalidRules, condition.Metric, rule.Code)
+			}
+			if _, ok := compare(condition.Op, 0, 0); !ok {
+				return nil, fmt.Errorf("%w: operador '%s' desconocido en '%s'", ErrInvalidRules, condition.Op, rule.Code)
+			}
+		}
+
+		message, err := template.New(rule.Code).Funcs(templateFuncs).Option("missingkey=error").Parse(rule.Message)
+		if err != nil {
+			return nil, fmt.Errorf("%w: plantilla de '%s': %v", ErrInvalidRules, rule.Code, err)
+		}
+		rule.message = message
+	}
+
+	sort.SliceStable(set.Rules, func(i, j int) bool {
+		if set.Rules[i].Priority != set.Rules[j].Priority {
+			return set.Rules[i].Priority > set.Rules[j].Priority
+		}
+		return set.Rules[i].Code < set.Rules[j].Code
+	})
+	return set.Rules, nil
+}
+
+// Evaluate devuelve todos los insights que se disparan para unas estadísticas, en orden de prioridad
+func (e *RuleEngine) Evaluate(stats StatsInput) []Insight {
+	e.reloadIfChanged()
+
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
+
+	metrics := stats.Metrics()
+	insights := []Insight{}
+	for _, rule := range rules {
+		if !rule.matches(metrics) {
+			continue
+		}
+
+		var message strings.Builder
+		if err := rule.message.Execute(&message, metrics); err != nil {
+			log.Printf("⚠️  [ADVISOR] Error en el mensaje de la regla %s: %v", rule.Code, err)
+			continue
+		}
+
+		used := make(map[string]float64, len(rule.When))
+		for _, condition := range rule.When {
+			used[condition.Metric] = metrics[condition.Metric]
+		}
+
+		insights = append(insights, Insight{
+			Code:     rule.Code,
+			Level:    rule.Level,
+			Title:    rule.Title,
+			Message:  message.String(),
+			Priority: rule.Priority,
+			Metrics:  used,
+			Source:   InsightSourceRules,
+		})
+		if rule.Stop {
+			break
+		}
+	}
+	return insights
+}
+
+// matches indica si se cumplen todas las condiciones de la regla
+func (r *Rule) matches(metrics map[string]float64) bool {
+	for _, condition := range r.When {
+		if ok, _ := compare(condition.Op, metrics[condition.Metric], condition.Value); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compare aplica un operador; known=false si el operador no existe
+func compare(op string, left, right float64) (result bool, known bool) {
+	switch op {
+	case "<":
+		return left < right, true
+	case "<=":
+		return left <= right, true
+	case ">":
+		return left > right, true
+	case ">=":
+		return left >= right, true
+	case "==":
+		return left == right, true
+	case "!=":
+		return left != right, true
+	}
+	return false, false
+}
+
+// reloadIfChanged vuelve a leer el fichero de reglas si se modificó.
+// Si el nuevo fichero es inválido se conservan las reglas anteriores.
+func (e *RuleEngine) reloadIfChanged() {
+	if e.path == "" {
+		return
+	}
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return
+	}
+
+	e.mu.RLock()
+	unchanged := info.ModTime().Equal(e.modTime)
+	e.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	if err := e.reload(); err != nil {
+		// Se recuerda la versión fallida para no reintentar (ni registrar) en cada evaluación
+		e.mu.Lock()
+		e.modTime = info.ModTime()
+		e.mu.Unlock()
+		log.Printf("⚠️  [ADVISOR] Reglas de %s no recargadas: %v", e.path, err)
+		return
+	}
+	log.Printf("🔄 [ADVISOR] Reglas recargadas desde %s", e.path)
+}
+
+// reload lee y sustituye las reglas del fichero
+func (e *RuleEngine) reload() error {
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return err
+	}
+	rules, err := ParseRules(data)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.rules = rules
+	e.modTime = info.ModTime()
+	e.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,214 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// ErrInsufficientHistory indica que no hay suficientes apuestas resueltas para simular
+var ErrInsufficientHistory = errors.New("historial insuficiente para simular")
+
+// Límites y valores por defecto de la simulación
+const (
+	MinSimulationSamples   = 10
+	DefaultSimulationPaths = 1000
+	MaxSimulationPaths     = 10000
+	DefaultSimulationBets  = 100
+	MaxSimulationBets      = 1000
+	DefaultRuinFraction    = 0.1 // Ruina = perder el 90% del bankroll inicial
+
+	// Número máximo de puntos de cada curva de percentiles
+	maxSimulationCheckpoints = 50
+)
+
+// simulationPercentiles son los percentiles que se devuelven para cada punto de la curva
+var simulationPercentiles = []float64{5, 25, 50, 75, 95}
+
+// SimulationSample es una apuesta histórica que se remuestrea en la simulación
+type SimulationSample struct {
+	Odds     float64
+	StakePct float64 // Stake como fracción del bankroll en el momento de apostar (0.02 = 2%)
+	Won      bool
+}
+
+// SimulationParams configura la simulación de Monte Carlo
+type SimulationParams struct {
+	Bankroll     float64 // Bankroll inicial
+	Paths        int     // Número de trayectorias simuladas
+	Bets         int     // Apuestas por trayectoria (horizonte)
+	Seed         int64   // Misma semilla + mismo historial = mismo resultado
+	RuinFraction float64 // Fracción del bankroll inicial por debajo de la cual hay ruina
+}
+
+// SimulationPoint son los percentiles del bankroll tras Bet apuestas
+type SimulationPoint struct {
+	Bet         int                `json:"bet"`
+	Percentiles map[string]float64 `json:"percentiles"` // "p5", "p25", "p50", "p75", "p95"
+}
+
+// SimulationResult resume las trayectorias simuladas
+type SimulationResult struct {
+	Seed         int64   `json:"seed"`
+	Paths        int     `json:"paths"`
+	Bets         int     `json:"bets"`
+	Samples      int     `json:"samples"`  // Apuestas históricas remuestreadas
+	HitRate      float64 `json:"hit_rate"` // Acierto histórico (%)
+	AvgOdds      float64 `json:"avg_odds"`
+	AvgStakePct  float64 `json:"avg_stake_pct"` // Stake medio histórico (% del bankroll)
+	Bankroll     float64 `json:"bankroll"`
+	RuinFraction float64 `json:"ruin_fraction"`
+
+	ProbDouble float64 `json:"prob_double"` // % de trayectorias que llegan a duplicar el bankroll
+	ProbRuin   float64 `json:"prob_ruin"`   // % de trayectorias que caen por debajo del umbral de ruina
+	ProbProfit float64 `json:"prob_profit"` // % de trayectorias que terminan con beneficio
+
+	Final  map[string]float64 `json:"final"` // Percentiles del bankroll final
+	Curves []SimulationPoint  `json:"curves"`
+}
+
+// SimulateBankroll remuestrea el historial (bootstrap) para simular params.Paths
+// trayectorias de params.Bets apuestas. En cada paso se elige una apuesta histórica al azar
+// y se repite con su cuota, su stake en % del bankroll actual y su resultado, de modo que
+// se conservan el acierto y la relación entre cuota y stake del usuario.
+func SimulateBankroll(samples []SimulationSample, params SimulationParams) (SimulationResult, error) {
+	if len(samples) < MinSimulationSamples {
+		return SimulationResult{}, fmt.Errorf("%w: se necesitan al menos %d apuestas resueltas", ErrInsufficientHistory, MinSimulationSamples)
+	}
+	params = params.withDefaults()
+
+	result := SimulationResult{
+		Seed:         params.Seed,
+		Paths:        params.Paths,
+		Bets:         params.Bets,
+		Samples:      len(samples),
+		Bankroll:     params.Bankroll,
+		RuinFraction: params.RuinFraction,
+	}
+
+	// 1. Resumen del historial
+	wins := 0
+	for _, sample := range samples {
+		if sample.Won {
+			wins++
+		}
+		result.AvgOdds += sample.Odds
+		result.AvgStakePct += sample.StakePct
+	}
+	result.HitRate = roundTo2(float64(wins) / float64(len(samples)) * 100)
+	result.AvgOdds = roundTo2(result.AvgOdds / float64(len(samples)))
+	result.AvgStakePct = roundTo2(result.AvgStakePct / float64(len(samples)) * 100)
+
+	// 2. Simular las trayectorias
+	checkpoints := simulationCheckpoints(params.Bets)
+	balances := make([][]float64, len(checkpoints))
+	for i := range balances {
+		balances[i] = make([]float64, params.Paths)
+	}
+	finals := make([]float64, params.Paths)
+
+	rng := rand.New(rand.NewSource(params.Seed))
+	ruinLevel := params.Bankroll * params.RuinFraction
+	doubled, ruined, profitable := 0, 0, 0
+
+	for path := 0; path < params.Paths; path++ {
+		bankroll := params.Bankroll
+		reachedDouble, reachedRuin := false, false
+		next := 0
+
+		for bet := 1; bet <= params.Bets; bet++ {
+			if !reachedRuin {
+				sample := samples[rng.Intn(len(samples))]
+				stake := bankroll * sample.StakePct
+				if sample.Won {
+					bankroll += stake * (sample.Odds - 1)
+				} else {
+					bankroll -= stake
+				}
+
+				if bankroll >= params.Bankroll*2 {
+					reachedDouble = true
+				}
+				// Una trayectoria arruinada deja de apostar
+				if bankroll <= ruinLevel {
+					reachedRuin = true
+				}
+			}
+
+			if next < len(checkpoints) && checkpoints[next] == bet {
+				balances[next][path] = bankroll
+				next++
+			}
+		}
+
+		finals[path] = bankroll
+		if reachedDouble {
+			doubled++
+		}
+		if reachedRuin {
+			ruined++
+		}
+		if bankroll > params.Bankroll {
+			profitable++
+		}
+	}
+
+	// 3. Probabilidades y percentiles
+	result.ProbDouble = roundTo2(float64(doubled) / float64(params.Paths) * 100)
+	result.ProbRuin = roundTo2(float64(ruined) / float64(params.Paths) * 100)
+	result.ProbProfit = roundTo2(float64(profitable) / float64(params.Paths) * 100)
+	result.Final = percentiles(finals)
+
+	result.Curves = make([]SimulationPoint, len(checkpoints))
+	for i, bet := range checkpoints {
+		result.Curves[i] = SimulationPoint{Bet: bet, Percentiles: percentiles(balances[i])}
+	}
+
+	return result, nil
+}
+
+// withDefaults completa y acota los parámetros de la simulación
+func (p SimulationParams) withDefaults() SimulationParams {
+	if p.Paths <= 0 {
+		p.Paths = DefaultSimulationPaths
+	}
+	if p.Paths > MaxSimulationPaths {
+		p.Paths = MaxSimulationPaths
+	}
+	if p.Bets <= 0 {
+		p.Bets = DefaultSimulationBets
+	}
+	if p.Bets > MaxSimulationBets {
+		p.Bets = MaxSimulationBets
+	}
+	if p.RuinFraction <= 0 || p.RuinFraction >= 1 {
+		p.RuinFraction = DefaultRuinFraction
+	}
+	return p
+}
+
+// simulationCheckpoints reparte hasta maxSimulationCheckpoints puntos entre 1 y bets (incluido)
+func simulationCheckpoints(bets int) []int {
+	step := int(math.Ceil(float64(bets) / maxSimulationCheckpoints))
+	checkpoints := make([]int, 0, maxSimulationCheckpoints+1)
+	for bet := step; bet < bets; bet += step {
+		checkpoints = append(checkpoints, bet)
+	}
+	return append(checkpoints, bets)
+}
+
+// percentiles ordena values (in situ) y devuelve los simulationPercentiles por el método del rango más cercano
+func percentiles(values []float64) map[string]float64 {
+	sort.Float64s(values)
+	result := make(map[string]float64, len(simulationPercentiles))
+	for _, p := range simulationPercentiles {
+		index := int(math.Ceil(p/100*float64(len(values)))) - 1
+		if index < 0 {
+			index = 0
+		}
+		result[fmt.Sprintf("p%.0f", p)] = roundTo2(values[index])
+	}
+	return result
+}
@@ -0,0 +1,147 @@
+package analytics
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// sampleHistory devuelve un historial mixto de n apuestas (acierto del 50%)
+func sampleHistory(n int) []SimulationSample {
+	samples := make([]SimulationSample, n)
+	for i := range samples {
+		samples[i] = SimulationSample{
+			Odds:     1.8 + float64(i%5)*0.1,
+			StakePct: 0.02,
+			Won:      i%2 == 0,
+		}
+	}
+	return samples
+}
+
+func TestSimulateBankrollSameSeed(t *testing.T) {
+	params := SimulationParams{Bankroll: 1000, Paths: 500, Bets: 120, Seed: 42}
+
+	first, err := SimulateBankroll(sampleHistory(30), params)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	second, err := SimulateBankroll(sampleHistory(30), params)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("la misma semilla dio resultados distintos:\n%+v\n%+v", first, second)
+	}
+}
+
+func TestSimulateBankrollDifferentSeed(t *testing.T) {
+	params := SimulationParams{Bankroll: 1000, Paths: 500, Bets: 120, Seed: 1}
+
+	first, err := SimulateBankroll(sampleHistory(30), params)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	params.Seed = 2
+	second, err := SimulateBankroll(sampleHistory(30), params)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if reflect.DeepEqual(first.Final, second.Final) && reflect.DeepEqual(first.Curves, second.Curves) {
+		t.Error("semillas distintas dieron las mismas trayectorias")
+	}
+}
+
+func TestSimulateBankrollInsufficientHistory(t *testing.T) {
+	_, err := SimulateBankroll(sampleHistory(MinSimulationSamples-1), SimulationParams{Bankroll: 1000})
+	if !errors.Is(err, ErrInsufficientHistory) {
+		t.Fatalf("se esperaba ErrInsufficientHistory, se obtuvo %v", err)
+	}
+
+	if _, err := SimulateBankroll(sampleHistory(MinSimulationSamples), SimulationParams{Bankroll: 1000}); err != nil {
+		t.Fatalf("con %d apuestas debería simular: %v", MinSimulationSamples, err)
+	}
+}
+
+func TestSimulateBankrollRuinAndDouble(t *testing.T) {
+	tests := []struct {
+		name       string
+		sample     SimulationSample
+		wantRuin   float64
+		wantDouble float64
+		wantProfit float64
+	}{
+		// Perder siempre la mitad del bankroll cae por debajo del 10% en 4 apuestas
+		{"siempre pierde", SimulationSample{Odds: 2, StakePct: 0.5, Won: false}, 100, 0, 0},
+		// Ganar siempre a cuota 2 con la mitad del bankroll multiplica por 1.5 cada apuesta
+		{"siempre gana", SimulationSample{Odds: 2, StakePct: 0.5, Won: true}, 0, 100, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples := make([]SimulationSample, MinSimulationSamples)
+			for i := range samples {
+				samples[i] = tt.sample
+			}
+
+			result, err := SimulateBankroll(samples, SimulationParams{Bankroll: 1000, Paths: 50, Bets: 20, Seed: 7})
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if result.ProbRuin != tt.wantRuin || result.ProbDouble != tt.wantDouble || result.ProbProfit != tt.wantProfit {
+				t.Errorf("ruina %v, duplicar %v, beneficio %v; se esperaba %v, %v, %v",
+					result.ProbRuin, result.ProbDouble, result.ProbProfit, tt.wantRuin, tt.wantDouble, tt.wantProfit)
+			}
+		})
+	}
+}
+
+func TestSimulationCheckpoints(t *testing.T) {
+	tests := []struct {
+		bets      int
+		wantLen   int
+		wantFirst int
+	}{
+		{1, 1, 1},
+		{10, 10, 1}, // Menos de 50 apuestas: un punto por apuesta
+		{50, 50, 1},
+		{100, 50, 2},
+		{101, 34, 3},
+		{1000, 50, 20},
+	}
+
+	for _, tt := range tests {
+		checkpoints := simulationCheckpoints(tt.bets)
+		if len(checkpoints) != tt.wantLen || checkpoints[0] != tt.wantFirst || checkpoints[len(checkpoints)-1] != tt.bets {
+			t.Errorf("simulationCheckpoints(%d) = %v: se esperaban %d puntos de %d a %d",
+				tt.bets, checkpoints, tt.wantLen, tt.wantFirst, tt.bets)
+		}
+		for i := 1; i < len(checkpoints); i++ {
+			if checkpoints[i] <= checkpoints[i-1] {
+				t.Errorf("simulationCheckpoints(%d) no es creciente: %v", tt.bets, checkpoints)
+				break
+			}
+		}
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	single := percentiles([]float64{500})
+	for key, value := range single {
+		if value != 500 {
+			t.Errorf("con un solo valor %s = %v, se esperaba 500", key, value)
+		}
+	}
+
+	// 100 valores desordenados: el rango más cercano coincide con el propio percentil
+	values := make([]float64, 100)
+	for i := range values {
+		values[i] = float64(100 - i)
+	}
+	want := map[string]float64{"p5": 5, "p25": 25, "p50": 50, "p75": 75, "p95": 95}
+	if got := percentiles(values); !reflect.DeepEqual(got, want) {
+		t.Errorf("percentiles = %v, se esperaba %v", got, want)
+	}
+}
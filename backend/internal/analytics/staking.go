@@ -0,0 +1,124 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrInvalidStaking indica una configuración de staking incoherente
+var ErrInvalidStaking = errors.New("configuración de staking inválida")
+
+// Métodos de staking soportados
+const (
+	StakingFlat            = "flat"             // Stake fijo en unidades
+	StakingPercentage      = "percentage"       // % fijo del bankroll
+	StakingKelly           = "kelly"            // Kelly completo
+	StakingFractionalKelly = "fractional_kelly" // Kelly * multiplicador (p. ej. 1/4 de Kelly)
+	StakingCappedKelly     = "capped_kelly"     // Kelly fraccional con un tope en % del bankroll
+)
+
+// StakingSettings es la configuración de staking de un usuario
+type StakingSettings struct {
+	Method          string
+	FlatStake       float64 // Unidades por apuesta (flat)
+	Percentage      float64 // % del bankroll por apuesta (percentage). 2 = 2%
+	KellyMultiplier float64 // Fracción de Kelly (fractional/capped). 0.25 = cuarto de Kelly
+	MaxStakePct     float64 // Tope en % del bankroll (capped_kelly)
+}
+
+// DefaultStakingSettings es la configuración recomendada: cuarto de Kelly con tope del 5%
+func DefaultStakingSettings() StakingSettings {
+	return StakingSettings{
+		Method:          StakingCappedKelly,
+		Percentage:      2,
+		KellyMultiplier: 0.25,
+		MaxStakePct:     5,
+	}
+}
+
+// Validate comprueba que la configuración tenga los parámetros de su método
+func (s StakingSettings) Validate() error {
+	switch s.Method {
+	case StakingFlat:
+		if s.FlatStake <= 0 {
+			return fmt.Errorf("%w: flat_stake debe ser mayor que 0", ErrInvalidStaking)
+		}
+	case StakingPercentage:
+		if s.Percentage <= 0 || s.Percentage > 100 {
+			return fmt.Errorf("%w: percentage debe estar entre 0 y 100", ErrInvalidStaking)
+		}
+	case StakingKelly:
+	case StakingFractionalKelly, StakingCappedKelly:
+		if s.KellyMultiplier <= 0 || s.KellyMultiplier > 1 {
+			return fmt.Errorf("%w: kelly_multiplier debe estar entre 0 y 1", ErrInvalidStaking)
+		}
+		if s.Method == StakingCappedKelly && (s.MaxStakePct <= 0 || s.MaxStakePct > 100) {
+			return fmt.Errorf("%w: max_stake_pct debe estar entre 0 y 100", ErrInvalidStaking)
+		}
+	default:
+		return fmt.Errorf("%w: método '%s' desconocido", ErrInvalidStaking, s.Method)
+	}
+	return nil
+}
+
+// UsesKelly indica si el método necesita la probabilidad estimada por el usuario
+func (s StakingSettings) UsesKelly() bool {
+	switch s.Method {
+	case StakingKelly, StakingFractionalKelly, StakingCappedKelly:
+		return true
+	}
+	return false
+}
+
+// StakeSuggestion es el stake recomendado para una apuesta concreta
+type StakeSuggestion struct {
+	Method        string  `json:"method"`
+	Stake         float64 `json:"stake"`
+	BankrollPct   float64 `json:"bankroll_pct"`   // Stake como % del bankroll
+	KellyFraction float64 `json:"kelly_fraction"` // Kelly completo (referencia)
+	ExpectedValue float64 `json:"expected_value"` // Por unidad apostada (0 si no hay probabilidad)
+	Capped        bool    `json:"capped"`         // true si se aplicó el tope
+}
+
+// SuggestStake calcula el stake para una apuesta con cuota 'odds' según la configuración.
+// probability es la probabilidad real estimada por el usuario; los métodos Kelly
+// la necesitan y devuelven stake 0 si la apuesta no tiene valor esperado positivo.
+// El stake nunca supera el bankroll.
+func SuggestStake(bankroll, odds, probability float64, settings StakingSettings) StakeSuggestion {
+	suggestion := StakeSuggestion{Method: settings.Method}
+	if probability > 0 {
+		suggestion.KellyFraction = KellyFraction(probability, odds)
+		suggestion.ExpectedValue = ExpectedValue(probability, odds)
+	}
+	if bankroll <= 0 {
+		return suggestion
+	}
+
+	stake := 0.0
+	switch settings.Method {
+	case StakingFlat:
+		stake = settings.FlatStake
+	case StakingPercentage:
+		stake = bankroll * settings.Percentage / 100
+	case StakingKelly:
+		stake = bankroll * suggestion.KellyFraction
+	case StakingFractionalKelly:
+		stake = bankroll * suggestion.KellyFraction * settings.KellyMultiplier
+	case StakingCappedKelly:
+		stake = bankroll * suggestion.KellyFraction * settings.KellyMultiplier
+		if limit := bankroll * settings.MaxStakePct / 100; stake > limit {
+			stake = limit
+			suggestion.Capped = true
+		}
+	}
+
+	if stake > bankroll {
+		stake = bankroll
+		suggestion.Capped = true
+	}
+
+	suggestion.Stake = math.Round(stake*100) / 100
+	suggestion.BankrollPct = suggestion.Stake / bankroll * 100
+	return suggestion
+}
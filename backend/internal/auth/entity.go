@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
 )
 
 // User representa al usuario en nuestro sistema.
@@ -22,6 +23,37 @@ type User struct {
 	Bankroll float64 `gorm:"default:0.00;type:decimal(15,2)" json:"bankroll"`
 	// ---------------------------------------
 
+	// Los administradores pueden resolver apuestas manualmente (se asigna directamente en la BD)
+	IsAdmin bool `gorm:"default:false" json:"is_admin"`
+
+	// Cómo quiere el usuario calcular sus stakes (columnas staking_*)
+	Staking StakingSettings `gorm:"embedded;embeddedPrefix:staking_" json:"staking"`
+
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
+
+// StakingSettings es la configuración de staking del usuario.
+// Los valores por defecto equivalen a analytics.DefaultStakingSettings (cuarto de Kelly con tope del 5%).
+type StakingSettings struct {
+	Method          string  `gorm:"default:'capped_kelly'" json:"method"` // flat, percentage, kelly, fractional_kelly, capped_kelly
+	FlatStake       float64 `gorm:"default:0" json:"flat_stake"`
+	Percentage      float64 `gorm:"default:2" json:"percentage"`
+	KellyMultiplier float64 `gorm:"default:0.25" json:"kelly_multiplier"`
+	MaxStakePct     float64 `gorm:"default:5" json:"max_stake_pct"`
+}
+
+// ToAnalytics convierte la configuración al formato del módulo de staking.
+// Un usuario sin método guardado usa la configuración por defecto.
+func (s StakingSettings) ToAnalytics() analytics.StakingSettings {
+	if s.Method == "" {
+		return analytics.DefaultStakingSettings()
+	}
+	return analytics.StakingSettings{
+		Method:          s.Method,
+		FlatStake:       s.FlatStake,
+		Percentage:      s.Percentage,
+		KellyMultiplier: s.KellyMultiplier,
+		MaxStakePct:     s.MaxStakePct,
+	}
+}
@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
 	"gorm.io/gorm"
 )
 
@@ -88,3 +91,26 @@ func (h *Handler) GetMe(c *fiber.Ctx) error {
 		"user": user,
 	})
 }
+
+// UpdateStakingHandler guarda la configuración de staking del usuario
+// @Router /api/me/staking [put]
+func (h *Handler) UpdateStakingHandler(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var req StakingSettings
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Datos inválidos"})
+	}
+
+	if err := h.service.UpdateStakingSettings(userID, req); err != nil {
+		if errors.Is(err, analytics.ErrInvalidStaking) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Error guardando la configuración"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Configuración de staking actualizada",
+		"staking": req,
+	})
+}
@@ -43,9 +43,22 @@ func Protected() fiber.Handler {
 		if ok && token.Valid {
 			// Guardamos el user_id en c.Locals para usarlo en los controladores
 			c.Locals("user_id", claims["user_id"])
+			isAdmin, _ := claims["is_admin"].(bool)
+			c.Locals("is_admin", isAdmin)
 		}
 
 		// 5. Dejar pasar a la siguiente función
 		return c.Next()
 	}
 }
+
+// AdminOnly bloquea las rutas de administración a usuarios sin rol de admin.
+// Debe ir después de Protected(), que es quien lee el rol del token.
+func AdminOnly() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if isAdmin, _ := c.Locals("is_admin").(bool); !isAdmin {
+			return c.Status(403).JSON(fiber.Map{"error": "Acceso restringido a administradores"})
+		}
+		return c.Next()
+	}
+}
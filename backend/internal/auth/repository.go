@@ -26,3 +26,14 @@ func (r *Repository) FindByEmail(email string) (*User, error) {
 	}
 	return &user, nil
 }
+
+// UpdateStaking guarda la configuración de staking del usuario
+func (r *Repository) UpdateStaking(userID string, settings StakingSettings) error {
+	return r.db.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"staking_method":           settings.Method,
+		"staking_flat_stake":       settings.FlatStake,
+		"staking_percentage":       settings.Percentage,
+		"staking_kelly_multiplier": settings.KellyMultiplier,
+		"staking_max_stake_pct":    settings.MaxStakePct,
+	}).Error
+}
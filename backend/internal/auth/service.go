@@ -76,6 +76,7 @@ func (s *Service) LoginUser(req LoginRequest) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id":  user.ID,
 		"username": user.Username,
+		"is_admin": user.IsAdmin,
 		"exp":      time.Now().Add(time.Hour * 72).Unix(), // Expira en 3 días
 	}
 
@@ -103,3 +104,11 @@ func (s *Service) GetUserProfile(id string) (*User, error) {
 	}
 	return &user, nil
 }
+
+// UpdateStakingSettings valida y guarda cómo quiere el usuario calcular sus stakes
+func (s *Service) UpdateStakingSettings(id string, settings StakingSettings) error {
+	if err := settings.ToAnalytics().Validate(); err != nil {
+		return err
+	}
+	return s.repo.UpdateStaking(id, settings)
+}
@@ -0,0 +1,48 @@
+package betting
+
+import (
+	"github.com/google/uuid"
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
+)
+
+// GetBankrollHistory reconstruye la curva del bankroll desde el extracto (transactions).
+// El saldo inicial se deduce del bankroll actual menos la suma de todos los movimientos,
+// así la curva termina siempre en el saldo real del usuario.
+func (s *Service) GetBankrollHistory(userID uuid.UUID, bucket string) (*analytics.BankrollHistory, error) {
+	// 1. Saldo actual y movimientos
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := s.repo.GetLedger(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Saldo inicial = saldo actual - suma del extracto
+	entries := make([]analytics.LedgerEntry, 0, len(transactions))
+	total := 0.0
+	for _, tx := range transactions {
+		entries = append(entries, analytics.LedgerEntry{At: tx.CreatedAt, Amount: tx.Amount})
+		total += tx.Amount
+	}
+
+	history := analytics.BuildBankrollHistory(user.Bankroll-total, entries, bucket)
+
+	// 3. Rachas perdedoras según el resultado neto de cada apuesta
+	bets, err := s.repo.GetResolvedBets(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profits := make([]float64, 0, len(bets))
+	for _, bet := range bets {
+		if profit, ok := settledProfit(bet); ok {
+			profits = append(profits, profit)
+		}
+	}
+	history.LongestLosingStreak, history.CurrentLosingStreak = analytics.LosingStreaks(profits)
+
+	return &history, nil
+}
@@ -0,0 +1,197 @@
+package betting
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidBreakdown indica una dimensión o rango de fechas no soportado
+var ErrInvalidBreakdown = errors.New("desglose inválido")
+
+// Dimensiones por las que se puede desglosar el rendimiento
+const (
+	BreakdownLeague  = "league"  // Liga del partido (las combinadas cuentan como 'parlay')
+	BreakdownOdds    = "odds"    // Tramo de cuota: <1.5, 1.5-2, 2-3, 3+
+	BreakdownWeekday = "weekday" // Día de la semana en que se apostó (1 = lunes ... 7 = domingo)
+	BreakdownHour    = "hour"    // Hora del día en que se apostó (00-23, zona horaria de la base de datos)
+	BreakdownStake   = "stake"   // Tamaño del stake respecto al stake medio del usuario
+)
+
+// BreakdownDimensions es el orden en que se devuelven los desgloses
+var BreakdownDimensions = []string{BreakdownLeague, BreakdownOdds, BreakdownWeekday, BreakdownHour, BreakdownStake}
+
+// breakdownColumn es la expresión SQL que agrupa una dimensión y la que ordena sus grupos
+type breakdownColumn struct {
+	key   string
+	order string
+}
+
+var breakdownColumns = map[string]breakdownColumn{
+	BreakdownLeague: {
+		key:   "CASE WHEN b.is_parlay THEN 'parlay' ELSE COALESCE(m.league, 'unknown') END",
+		order: "key",
+	},
+	BreakdownOdds: {
+		key: `CASE
+                WHEN b.odds < 1.5 THEN '<1.5'
+                WHEN b.odds < 2 THEN '1.5-2'
+                WHEN b.odds < 3 THEN '2-3'
+                ELSE '3+' END`,
+		order: "MIN(b.odds)",
+	},
+	BreakdownWeekday: {key: "to_char(b.created_at, 'ID')", order: "key"},
+	BreakdownHour:    {key: "to_char(b.created_at, 'HH24')", order: "key"},
+	BreakdownStake: {
+		// Los límites (0.5x, 1x, 2x del stake medio) se pasan como parámetros
+		key: `CASE
+                WHEN b.stake_units < ? THEN '<0.5x'
+                WHEN b.stake_units < ? THEN '0.5x-1x'
+                WHEN b.stake_units < ? THEN '1x-2x'
+                ELSE '2x+' END`,
+		order: "MIN(b.stake_units)",
+	},
+}
+
+// weekdayLabels traduce el día ISO (1-7) a su nombre
+var weekdayLabels = map[string]string{
+	"1": "Lunes", "2": "Martes", "3": "Miércoles", "4": "Jueves",
+	"5": "Viernes", "6": "Sábado", "7": "Domingo",
+}
+
+// BreakdownFilter limita el desglose a las apuestas realizadas en [From, To)
+type BreakdownFilter struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// BreakdownRow es el rendimiento de un grupo dentro de una dimensión
+type BreakdownRow struct {
+	Key      string  `json:"key"`
+	Label    string  `json:"label"`
+	Bets     int64   `json:"bets"`
+	Won      int64   `json:"won"`
+	Lost     int64   `json:"lost"`
+	WinRate  float64 `json:"win_rate"` // Sobre ganadas + perdidas
+	Wagered  float64 `json:"wagered"`
+	Returned float64 `json:"returned"`
+	Profit   float64 `json:"profit"`
+	ROI      float64 `json:"roi"`
+}
+
+// BreakdownResponse agrupa los desgloses pedidos por dimensión
+type BreakdownResponse struct {
+	From       *time.Time                `json:"from,omitempty"`
+	To         *time.Time                `json:"to,omitempty"`
+	Dimensions map[string][]BreakdownRow `json:"dimensions"`
+}
+
+// GetBreakdown agrega en SQL las apuestas resueltas del usuario por una dimensión
+func (r *Repository) GetBreakdown(userID uuid.UUID, dimension string, filter BreakdownFilter) ([]BreakdownRow, error) {
+	column, ok := breakdownColumns[dimension]
+	if !ok {
+		return nil, fmt.Errorf("%w: dimensión '%s' desconocida", ErrInvalidBreakdown, dimension)
+	}
+
+	// 1. Parámetros de la expresión (solo el tramo de stake los necesita)
+	var keyArgs []interface{}
+	if dimension == BreakdownStake {
+		avgStake, err := r.averageStake(userID, filter)
+		if err != nil {
+			return nil, err
+		}
+		keyArgs = []interface{}{avgStake * 0.5, avgStake, avgStake * 2}
+	}
+
+	// 2. Agregar
+	var rows []BreakdownRow
+	query := r.db.Table("bets b").
+		Select(column.key+` as key,
+            COUNT(*) as bets,
+            COUNT(*) FILTER (WHERE b.status = 'WON') as won,
+            COUNT(*) FILTER (WHERE b.status = 'LOST') as lost,
+            COALESCE(SUM(b.stake_units), 0) as wagered,
+            COALESCE(SUM(CASE
+                WHEN b.status = 'WON' AND b.payout = 0 THEN b.stake_units * b.odds
+                ELSE b.payout END), 0) as returned
+        `, keyArgs...)
+	if dimension == BreakdownLeague {
+		// Las apuestas antiguas sin selecciones guardan el partido en details.match_id
+		query = query.
+			Joins("LEFT JOIN bet_legs l ON l.bet_id = b.id AND NOT b.is_parlay").
+			Joins("LEFT JOIN matches m ON m.id::text = COALESCE(l.match_id::text, b.details->>'match_id')")
+	}
+	err := filter.apply(query.Where("b.user_id = ? AND b.status <> ?", userID, StatusPending)).
+		Group("key").
+		Order(column.order).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Métricas derivadas
+	for i := range rows {
+		row := &rows[i]
+		row.Label = row.Key
+		if dimension == BreakdownWeekday {
+			row.Label = weekdayLabels[row.Key]
+		}
+		row.Profit = row.Returned - row.Wagered
+		if row.Wagered > 0 {
+			row.ROI = row.Profit / row.Wagered * 100
+		}
+		if resolved := row.Won + row.Lost; resolved > 0 {
+			row.WinRate = float64(row.Won) / float64(resolved) * 100
+		}
+	}
+	return rows, nil
+}
+
+// averageStake devuelve el stake medio de las apuestas resueltas del usuario en el rango
+func (r *Repository) averageStake(userID uuid.UUID, filter BreakdownFilter) (float64, error) {
+	var avg float64
+	err := filter.apply(r.db.Table("bets b").
+		Select("COALESCE(AVG(b.stake_units), 0)").
+		Where("b.user_id = ? AND b.status <> ?", userID, StatusPending)).
+		Scan(&avg).Error
+	return avg, err
+}
+
+// GetBreakdown devuelve el rendimiento desglosado por las dimensiones pedidas
+// (todas si dimensions está vacío) para que el frontend pueda pivotar sobre ellas.
+func (s *Service) GetBreakdown(userID uuid.UUID, dimensions []string, filter BreakdownFilter) (*BreakdownResponse, error) {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, fmt.Errorf("%w: 'from' debe ser anterior a 'to'", ErrInvalidBreakdown)
+	}
+	if len(dimensions) == 0 {
+		dimensions = BreakdownDimensions
+	}
+
+	response := &BreakdownResponse{
+		From:       filter.From,
+		To:         filter.To,
+		Dimensions: make(map[string][]BreakdownRow, len(dimensions)),
+	}
+	for _, dimension := range dimensions {
+		rows, err := s.repo.GetBreakdown(userID, dimension, filter)
+		if err != nil {
+			return nil, err
+		}
+		response.Dimensions[dimension] = rows
+	}
+	return response, nil
+}
+
+// apply añade el rango de fechas a una consulta sobre bets (alias b)
+func (f BreakdownFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.From != nil {
+		query = query.Where("b.created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("b.created_at < ?", *f.To)
+	}
+	return query
+}
@@ -0,0 +1,30 @@
+package betting
+
+import (
+	"github.com/google/uuid"
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
+)
+
+// GetCalibrationReport compara las probabilidades que el usuario estimó al apostar con su
+// acierto real. Solo cuentan las apuestas ganadas o perdidas con probabilidad registrada.
+func (s *Service) GetCalibrationReport(userID uuid.UUID, buckets int) (*analytics.CalibrationReport, error) {
+	bets, err := s.repo.GetResolvedBets(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]analytics.CalibrationSample, 0, len(bets))
+	for _, bet := range bets {
+		if bet.EstimatedProbability == nil || (bet.Status != StatusWon && bet.Status != StatusLost) {
+			continue
+		}
+		samples = append(samples, analytics.CalibrationSample{
+			Probability:       *bet.EstimatedProbability,
+			MarketProbability: analytics.ImpliedProbability(bet.Odds),
+			Won:               bet.Status == StatusWon,
+		})
+	}
+
+	report := analytics.BuildCalibrationReport(samples, buckets)
+	return &report, nil
+}
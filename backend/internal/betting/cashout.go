@@ -0,0 +1,138 @@
+package betting
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrCashoutUnavailable indica que la apuesta no admite cash-out en este momento
+var ErrCashoutUnavailable = errors.New("cash-out no disponible")
+
+// cashoutMargin es el margen que retiene la casa sobre el valor justo del cash-out
+const cashoutMargin = 0.05
+
+// defaultQuoteTTL es la validez de una oferta si no se configura CASHOUT_QUOTE_TTL_SECONDS
+const defaultQuoteTTL = 10 * time.Second
+
+// cashoutValue valora el stake abierto con la cuota actual del mercado.
+// Valor justo = stake * cuota apostada / cuota actual, menos el margen de la casa.
+func cashoutValue(stake, placedOdds, currentOdds float64) float64 {
+	if currentOdds <= 0 {
+		return 0
+	}
+	value := stake * placedOdds / currentOdds * (1 - cashoutMargin)
+	return math.Round(value*100) / 100
+}
+
+// quoteTTL lee la validez de las ofertas desde el entorno
+func quoteTTL() time.Duration {
+	if raw := os.Getenv("CASHOUT_QUOTE_TTL_SECONDS"); raw != "" {
+		if seconds, err := strconv.Atoi(raw); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultQuoteTTL
+}
+
+// QuoteCashout calcula una oferta de cash-out para cerrar una fracción del stake abierto.
+// fraction: 1 cierra toda la apuesta; 0.5 cierra la mitad del stake que sigue en juego.
+func (s *Service) QuoteCashout(userID, betID uuid.UUID, fraction float64) (*CashoutQuote, error) {
+	if fraction <= 0 || fraction > 1 {
+		return nil, fmt.Errorf("%w: la fracción debe estar entre 0 y 1", ErrInvalidBet)
+	}
+
+	// 1. Validar la apuesta
+	bet, err := s.repo.GetBetByID(betID)
+	if err != nil {
+		return nil, err
+	}
+	if bet.UserID != userID {
+		return nil, fmt.Errorf("%w: la apuesta no pertenece al usuario", ErrInvalidBet)
+	}
+	if bet.Status != StatusPending {
+		return nil, fmt.Errorf("%w: la apuesta ya está resuelta", ErrCashoutUnavailable)
+	}
+	if len(bet.Legs) == 0 {
+		return nil, fmt.Errorf("%w: la apuesta no está vinculada a un mercado", ErrCashoutUnavailable)
+	}
+
+	// 2. Cuota efectiva apostada y cuota actual de las selecciones pendientes
+	// Las selecciones ya liquidadas (ganadas o a medias) no tienen riesgo (cuota actual 1.00)
+	// y las anuladas no cuentan en ninguno de los dos lados.
+	placedOdds, currentOdds := 1.0, 1.0
+	for _, leg := range bet.Legs {
+		switch leg.Status {
+		case StatusLost:
+			return nil, fmt.Errorf("%w: una selección ya ha perdido", ErrCashoutUnavailable)
+		case StatusVoid, StatusPush:
+			continue
+		case StatusWon, StatusHalfWon, StatusHalfLost:
+			placedOdds *= payoutFactor(leg.Status, leg.Odds)
+		default:
+			price, err := s.currentLegOdds(leg)
+			if err != nil {
+				return nil, err
+			}
+			placedOdds *= leg.Odds
+			currentOdds *= price
+		}
+	}
+
+	// 3. Valorar la fracción solicitada
+	openStake := bet.OpenStake()
+	stake := math.Round(openStake*fraction*100) / 100
+	if fraction == 1 {
+		stake = openStake
+	}
+
+	quote := &CashoutQuote{
+		BetID:       bet.ID,
+		UserID:      userID,
+		Fraction:    fraction,
+		OpenStake:   openStake,
+		Stake:       stake,
+		Amount:      cashoutValue(stake, placedOdds, currentOdds),
+		CurrentOdds: math.Round(currentOdds*100) / 100,
+		ExpiresAt:   time.Now().Add(quoteTTL()),
+	}
+
+	if err := s.repo.CreateCashoutQuote(quote); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// currentLegOdds devuelve el precio actual de mercado de una selección pendiente
+func (s *Service) currentLegOdds(leg BetLeg) (float64, error) {
+	match, err := s.repo.GetMatchByID(leg.MatchID)
+	if err != nil {
+		return 0, fmt.Errorf("%w: partido no encontrado", ErrCashoutUnavailable)
+	}
+	if match.Status == "finished" || match.Status == "removed" {
+		return 0, fmt.Errorf("%w: el mercado está cerrado", ErrCashoutUnavailable)
+	}
+
+	price := match.OddsFor(leg.Selection)
+	if leg.MarketLineID != nil {
+		line, err := s.repo.GetMarketLine(*leg.MarketLineID)
+		if err != nil || line.Status != "open" {
+			return 0, fmt.Errorf("%w: la línea ya no está disponible", ErrCashoutUnavailable)
+		}
+		price = line.OddsFor(leg.Selection)
+	}
+	if price <= 1 {
+		return 0, fmt.Errorf("%w: el mercado está cerrado", ErrCashoutUnavailable)
+	}
+	return price, nil
+}
+
+// AcceptCashout ejecuta una oferta vigente y devuelve la apuesta actualizada
+func (s *Service) AcceptCashout(userID, betID, quoteID uuid.UUID) (*Bet, error) {
+	return s.repo.AcceptCashout(userID, betID, quoteID)
+}
@@ -0,0 +1,44 @@
+package betting
+
+import (
+	"log"
+
+	"github.com/xnzperez/sports-analytics-backend/internal/market"
+)
+
+// clvFromLegs calcula la cuota de cierre y el CLV (%) de una apuesta a partir de sus selecciones.
+// Las selecciones anuladas o con PUSH no cuentan. ok=false si falta el cierre de alguna selección.
+func clvFromLegs(legs []BetLeg) (closingOdds float64, clv float64, ok bool) {
+	placed, closing := 1.0, 1.0
+	counted := 0
+
+	for _, leg := range legs {
+		if leg.Status == StatusVoid || leg.Status == StatusPush {
+			continue
+		}
+		if leg.ClosingOdds <= 0 {
+			return 0, 0, false
+		}
+		placed *= leg.Odds
+		closing *= leg.ClosingOdds
+		counted++
+	}
+
+	if counted == 0 {
+		return 0, 0, false
+	}
+	return closing, (placed/closing - 1) * 100, true
+}
+
+// AttachClosingOdds copia el precio de cierre de un partido a todas las selecciones
+// apostadas en él y actualiza el CLV de las apuestas afectadas.
+func (s *Service) AttachClosingOdds(match *market.Match) error {
+	count, err := s.repo.AttachClosingOdds(match)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		log.Printf("📈 CLV calculado para %d apuestas del partido %s", count, match.ID)
+	}
+	return nil
+}
@@ -6,6 +6,20 @@ import (
 	"github.com/google/uuid"
 )
 
+// Estados posibles de una apuesta y de cada una de sus selecciones.
+const (
+	StatusPending = "pending"
+	StatusWon     = "WON"
+	StatusLost    = "LOST"
+	StatusVoid    = "VOID"    // Partido cancelado o selección anulada
+	StatusPush    = "PUSH"    // Empate contra la línea: se devuelve el stake
+	StatusCashout = "CASHOUT" // Cerrada antes de tiempo por un importe acordado
+
+	// Líneas asiáticas de cuarto: la mitad del stake gana (o pierde) y la otra se devuelve
+	StatusHalfWon  = "HALF_WON"
+	StatusHalfLost = "HALF_LOST"
+)
+
 // Bet representa una apuesta en el sistema.
 // Usamos tags de GORM para definir la estructura exacta en la base de datos
 // y tags JSON para la respuesta de la API.
@@ -20,6 +34,20 @@ type Bet struct {
 
 	StakeUnits float64 `gorm:"not null" json:"stake_units"`
 	Odds       float64 `gorm:"not null" json:"odds"`
+	Payout     float64 `gorm:"default:0" json:"payout"` // Total devuelto al usuario al liquidar
+
+	// SettlementFactor es lo que pagó cada unidad de stake abierto al liquidar.
+	// En una combinada con selecciones anuladas o a medias difiere de Odds, que no se toca.
+	SettlementFactor float64 `gorm:"default:0" json:"settlement_factor"`
+
+	// Precio de cierre del mercado y Closing Line Value (%) = (Odds / ClosingOdds - 1) * 100.
+	// Quedan vacíos hasta que comienzan todos los partidos de la apuesta.
+	ClosingOdds float64  `gorm:"default:0" json:"closing_odds"`
+	CLV         *float64 `gorm:"column:clv" json:"clv,omitempty"`
+
+	// ClosedStake es la parte del stake ya cerrada con cash-out parcial.
+	// El resto (StakeUnits - ClosedStake) sigue en juego.
+	ClosedStake float64 `gorm:"default:0" json:"closed_stake"`
 
 	// Details se guarda como JSONB en Postgres para poder hacer consultas avanzadas dentro del JSON en el futuro.
 	// En Go lo manejamos como string (o []byte) conteniendo el JSON crudo.
@@ -27,6 +55,10 @@ type Bet struct {
 
 	UserNotes string `json:"user_notes"`
 
+	// Probabilidad de acierto que estimó el usuario al apostar (0-1). Opcional;
+	// alimenta el informe de calibración.
+	EstimatedProbability *float64 `gorm:"column:estimated_probability" json:"estimated_probability,omitempty"`
+
 	// --- CORRECCIÓN ---
 	// Usamos `column:ai_prediction` para evitar que GORM genere "a_iprediction".
 	// Usamos *string (puntero) para que si no hay predicción, se guarde como NULL en la BD.
@@ -37,6 +69,9 @@ type Bet struct {
 
 	ExternalID string `json:"external_id" gorm:"index"` // Index para búsquedas rápidas
 	Provider   string `json:"provider"`                 // 'pinnacle', 'api-sports', etc.
+
+	// Legs contiene las selecciones de la apuesta (una para simples, varias para combinadas).
+	Legs []BetLeg `gorm:"foreignKey:BetID" json:"legs,omitempty"`
 }
 
 // TableName anula la pluralización por defecto de GORM si fuera necesario,
@@ -45,6 +80,63 @@ func (Bet) TableName() string {
 	return "bets"
 }
 
+// BetLeg representa una selección individual dentro de una apuesta.
+// Cada leg apunta a un partido (market.Match) y se liquida por separado;
+// la apuesta padre se resuelve cuando el conjunto de legs tiene un resultado definitivo.
+type BetLeg struct {
+	ID      uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BetID   uuid.UUID `gorm:"type:uuid;not null;index" json:"bet_id"`
+	MatchID uuid.UUID `gorm:"type:uuid;not null;index" json:"match_id"`
+
+	// Mercado de la selección. En moneyline MarketLineID es nil y se usa el precio del Match;
+	// en hándicaps y totales apunta a la market.MarketLine apostada.
+	MarketLineID *uuid.UUID `gorm:"type:uuid;index" json:"market_line_id,omitempty"`
+	MarketType   string     `gorm:"default:'moneyline'" json:"market_type"`
+	Period       int        `gorm:"default:0" json:"period"` // 0 = partido completo
+	Line         float64    `gorm:"default:0" json:"line"`   // Hándicap del local o total de puntos
+
+	Selection string  `gorm:"not null" json:"selection"` // "HOME", "AWAY", "DRAW", "OVER" o "UNDER"
+	TeamName  string  `json:"team_name"`
+	Odds      float64 `gorm:"not null" json:"odds"`
+	Status    string  `gorm:"default:'pending'" json:"status"`
+
+	ClosingOdds float64 `gorm:"default:0" json:"closing_odds"` // Cuota de la selección al comenzar el partido
+
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	ResultedAt *time.Time `json:"resulted_at,omitempty"`
+}
+
+func (BetLeg) TableName() string {
+	return "bet_legs"
+}
+
+// OpenStake devuelve la parte del stake que sigue en juego
+func (b *Bet) OpenStake() float64 {
+	return b.StakeUnits - b.ClosedStake
+}
+
+// CashoutQuote es una oferta de cash-out con validez limitada.
+// El usuario debe aceptarla antes de ExpiresAt; después hay que pedir otra.
+type CashoutQuote struct {
+	ID     uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BetID  uuid.UUID `gorm:"type:uuid;not null;index" json:"bet_id"`
+	UserID uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+
+	Fraction    float64 `gorm:"not null" json:"fraction"`     // Parte del stake abierto que se cierra (0-1]
+	OpenStake   float64 `gorm:"not null" json:"open_stake"`   // Stake abierto al momento de cotizar
+	Stake       float64 `gorm:"not null" json:"stake"`        // Stake que se cierra con esta oferta
+	Amount      float64 `gorm:"not null" json:"amount"`       // Importe que se paga al aceptar
+	CurrentOdds float64 `gorm:"not null" json:"current_odds"` // Cuota de mercado usada para valorar
+
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (CashoutQuote) TableName() string {
+	return "cashout_quotes"
+}
+
 // Transaction representa cualquier movimiento de dinero en la cuenta del usuario.
 // Esto es vital para auditoría y para mostrar el "Extracto Bancario".
 type Transaction struct {
@@ -0,0 +1,102 @@
+package betting
+
+import (
+	"math"
+
+	"github.com/xnzperez/sports-analytics-backend/internal/market"
+)
+
+// Liquidación de hándicaps y totales (incluidas las líneas asiáticas de cuarto).
+//
+// Una línea de cuarto (-0.25, +0.75, 2.25...) divide el stake en dos mitades apostadas
+// a las líneas vecinas (-0.25 = mitad a 0 y mitad a -0.5). Cada mitad se liquida por
+// separado, así que el resultado puede ser medio ganado o medio perdido:
+//
+//	Mitades        Estado      Pago
+//	WON  + WON     WON         stake * cuota
+//	WON  + PUSH    HALF_WON    stake/2 * cuota + stake/2
+//	PUSH + LOST    HALF_LOST   stake/2
+//	LOST + LOST    LOST        0
+//
+// lineOutcome da el estado de una selección a partir del marcador y settlementPayout el pago
+// exacto para ese estado. El pago se usa en los dos caminos de liquidación: por marcador
+// (SettleMatchLegs) y manual (ResolveBet con outcome HALF_WON / HALF_LOST).
+
+// lineOutcome calcula el estado de una selección de línea a partir del marcador.
+// En líneas enteras el empate contra la línea es PUSH (se devuelve el stake).
+//   - spread: line es el hándicap del local; el visitante juega con -line.
+//   - total: line es el total de puntos (mapas o rondas) del periodo.
+func lineOutcome(marketType, selection string, line float64, homeScore, awayScore int) string {
+	if !isQuarterLine(line) {
+		return halfLineOutcome(marketType, selection, line, homeScore, awayScore)
+	}
+
+	lower := halfLineOutcome(marketType, selection, line-0.25, homeScore, awayScore)
+	upper := halfLineOutcome(marketType, selection, line+0.25, homeScore, awayScore)
+	return combineHalves(lower, upper)
+}
+
+// halfLineOutcome liquida una línea entera o de medio punto
+func halfLineOutcome(marketType, selection string, line float64, homeScore, awayScore int) string {
+	var margin float64
+	switch marketType {
+	case market.MarketTypeSpread:
+		margin = float64(homeScore-awayScore) + line
+		if selection == "AWAY" {
+			margin = -margin
+		}
+	case market.MarketTypeTotal:
+		margin = float64(homeScore+awayScore) - line
+		if selection == "UNDER" {
+			margin = -margin
+		}
+	default:
+		return StatusVoid
+	}
+
+	switch {
+	case margin > 0:
+		return StatusWon
+	case margin < 0:
+		return StatusLost
+	}
+	return StatusPush
+}
+
+// combineHalves une el resultado de las dos mitades de una línea de cuarto
+func combineHalves(a, b string) string {
+	if a == b {
+		return a
+	}
+	if a == StatusWon || b == StatusWon {
+		// Las dos líneas vecinas están a medio punto: WON + LOST no es posible
+		return StatusHalfWon
+	}
+	return StatusHalfLost
+}
+
+// isQuarterLine indica si la línea termina en .25 o .75
+func isQuarterLine(line float64) bool {
+	return math.Abs(math.Mod(line*4, 2)) == 1
+}
+
+// settlementPayout calcula el pago de un stake según su estado final
+func settlementPayout(status string, stake, odds float64) float64 {
+	return stake * payoutFactor(status, odds)
+}
+
+// payoutFactor es lo que se devuelve por cada unidad apostada según el estado.
+// En combinadas se multiplica el factor de cada selección.
+func payoutFactor(status string, odds float64) float64 {
+	switch status {
+	case StatusWon:
+		return odds
+	case StatusHalfWon:
+		return (odds + 1) / 2
+	case StatusVoid, StatusPush:
+		return 1
+	case StatusHalfLost:
+		return 0.5
+	}
+	return 0
+}
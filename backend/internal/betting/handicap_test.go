@@ -0,0 +1,130 @@
+package betting
+
+import (
+	"math"
+	"testing"
+
+	"github.com/xnzperez/sports-analytics-backend/internal/market"
+)
+
+func TestLineOutcome(t *testing.T) {
+	tests := []struct {
+		name       string
+		marketType string
+		selection  string
+		line       float64
+		home, away int
+		want       string
+	}{
+		// Líneas enteras y de medio punto
+		{"hándicap entero gana", market.MarketTypeSpread, "HOME", -1, 3, 1, StatusWon},
+		{"hándicap entero push", market.MarketTypeSpread, "HOME", -1, 2, 1, StatusPush},
+		{"hándicap entero pierde", market.MarketTypeSpread, "HOME", -1, 1, 1, StatusLost},
+		{"medio punto gana", market.MarketTypeSpread, "HOME", -1.5, 3, 1, StatusWon},
+		{"medio punto pierde", market.MarketTypeSpread, "HOME", -1.5, 2, 1, StatusLost},
+		{"visitante juega con la línea opuesta", market.MarketTypeSpread, "AWAY", -1.5, 2, 1, StatusWon},
+
+		// -0.25 = mitad a 0 y mitad a -0.5
+		{"-0.25 gana", market.MarketTypeSpread, "HOME", -0.25, 2, 1, StatusWon},
+		{"-0.25 empate medio perdido", market.MarketTypeSpread, "HOME", -0.25, 1, 1, StatusHalfLost},
+		{"-0.25 pierde", market.MarketTypeSpread, "HOME", -0.25, 0, 1, StatusLost},
+		{"visitante +0.25 empate medio ganado", market.MarketTypeSpread, "AWAY", -0.25, 1, 1, StatusHalfWon},
+
+		// -0.75 = mitad a -0.5 y mitad a -1
+		{"-0.75 gana por dos", market.MarketTypeSpread, "HOME", -0.75, 3, 1, StatusWon},
+		{"-0.75 gana por uno medio ganado", market.MarketTypeSpread, "HOME", -0.75, 2, 1, StatusHalfWon},
+		{"-0.75 empate pierde", market.MarketTypeSpread, "HOME", -0.75, 1, 1, StatusLost},
+
+		// +0.25 = mitad a 0 y mitad a +0.5
+		{"+0.25 gana", market.MarketTypeSpread, "HOME", 0.25, 2, 1, StatusWon},
+		{"+0.25 empate medio ganado", market.MarketTypeSpread, "HOME", 0.25, 1, 1, StatusHalfWon},
+		{"+0.25 pierde", market.MarketTypeSpread, "HOME", 0.25, 0, 1, StatusLost},
+
+		// Totales
+		{"total medio punto over", market.MarketTypeTotal, "OVER", 2.5, 2, 1, StatusWon},
+		{"total medio punto under", market.MarketTypeTotal, "UNDER", 2.5, 2, 1, StatusLost},
+		{"total entero push", market.MarketTypeTotal, "OVER", 3, 2, 1, StatusPush},
+		{"total 2.25 over medio perdido", market.MarketTypeTotal, "OVER", 2.25, 1, 1, StatusHalfLost},
+		{"total 2.25 under medio ganado", market.MarketTypeTotal, "UNDER", 2.25, 1, 1, StatusHalfWon},
+		{"total 2.75 over medio ganado", market.MarketTypeTotal, "OVER", 2.75, 2, 1, StatusHalfWon},
+
+		{"mercado desconocido se anula", market.MarketTypeMoneyline, "HOME", -0.5, 2, 1, StatusVoid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lineOutcome(tt.marketType, tt.selection, tt.line, tt.home, tt.away)
+			if got != tt.want {
+				t.Errorf("lineOutcome(%s, %s, %v, %d-%d) = %s, se esperaba %s",
+					tt.marketType, tt.selection, tt.line, tt.home, tt.away, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCombineHalves(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{StatusWon, StatusWon, StatusWon},
+		{StatusWon, StatusPush, StatusHalfWon},
+		{StatusPush, StatusWon, StatusHalfWon},
+		{StatusPush, StatusLost, StatusHalfLost},
+		{StatusLost, StatusPush, StatusHalfLost},
+		{StatusLost, StatusLost, StatusLost},
+		{StatusPush, StatusPush, StatusPush},
+	}
+
+	for _, tt := range tests {
+		if got := combineHalves(tt.a, tt.b); got != tt.want {
+			t.Errorf("combineHalves(%s, %s) = %s, se esperaba %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSettlementPayout(t *testing.T) {
+	const stake, odds = 100.0, 1.9
+
+	tests := []struct {
+		status string
+		want   float64
+	}{
+		{StatusWon, 190},
+		{StatusHalfWon, 145}, // 50 * 1.9 + 50
+		{StatusPush, 100},
+		{StatusVoid, 100},
+		{StatusHalfLost, 50},
+		{StatusLost, 0},
+		{StatusCashout, 0}, // El importe del cash-out lo fija quien liquida
+	}
+
+	for _, tt := range tests {
+		if got := settlementPayout(tt.status, stake, odds); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("settlementPayout(%s) = %v, se esperaba %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+// Una línea de cuarto liquidada por marcador paga lo mismo que la tabla de handicap.go
+func TestQuarterLinePayout(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       float64
+		home, away int
+		want       float64
+	}{
+		{"-0.25 empate devuelve la mitad", -0.25, 1, 1, 50},
+		{"-0.75 gana por uno cobra media apuesta", -0.75, 2, 1, 150},
+		{"+0.25 empate cobra media apuesta", 0.25, 1, 1, 150},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := lineOutcome(market.MarketTypeSpread, "HOME", tt.line, tt.home, tt.away)
+			if got := settlementPayout(status, 100, 2); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("pago = %v (%s), se esperaba %v", got, status, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,16 @@
 package betting
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/xnzperez/sports-analytics-backend/internal/ai"
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
+	"github.com/xnzperez/sports-analytics-backend/internal/market"
 
 	// "auth" lo quitamos porque ya no lo necesitamos aquí
 	"gorm.io/gorm"
@@ -48,10 +55,7 @@ func (h *Handler) PlaceBet(c *fiber.Ctx) error {
 	// 4. Llamar al servicio
 	bet, err := h.service.PlaceBet(userID, req)
 	if err != nil {
-		if err.Error() == "saldo insuficiente para realizar esta apuesta" {
-			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
-		}
-		return c.Status(500).JSON(fiber.Map{"error": "Error interno al procesar apuesta"})
+		return placeBetError(c, err)
 	}
 
 	return c.Status(201).JSON(fiber.Map{
@@ -60,12 +64,79 @@ func (h *Handler) PlaceBet(c *fiber.Ctx) error {
 	})
 }
 
+// PreviewBetHandler simula una apuesta sin confirmarla: valor esperado,
+// stake sugerido (staking del usuario) e impacto en el bankroll. stake_units es opcional.
+// @Router /api/bets/preview [post]
+func (h *Handler) PreviewBetHandler(c *fiber.Ctx) error {
+	userID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	var req PlaceBetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Datos inválidos"})
+	}
+	if req.StakeUnits < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "El stake no puede ser negativo"})
+	}
+
+	preview, err := h.service.PreviewBet(userID, req)
+	if err != nil {
+		return placeBetError(c, err)
+	}
+
+	return c.JSON(preview)
+}
+
+// StakingSuggestHandler sugiere el stake para una apuesta según la configuración del usuario.
+// Query: odds (obligatorio) y probability (0-1, obligatorio para los métodos Kelly).
+// @Router /api/staking/suggest [get]
+func (h *Handler) StakingSuggestHandler(c *fiber.Ctx) error {
+	userID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	odds := c.QueryFloat("odds", 0)
+	probability := c.QueryFloat("probability", 0)
+	if odds <= 1 {
+		return c.Status(400).JSON(fiber.Map{"error": "La cuota (odds) debe ser mayor que 1"})
+	}
+	if probability < 0 || probability >= 1 {
+		return c.Status(400).JSON(fiber.Map{"error": "La probabilidad debe estar entre 0 y 1"})
+	}
+
+	suggestion, err := h.service.SuggestStake(userID, odds, probability)
+	if err != nil {
+		if errors.Is(err, ErrInvalidBet) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Error calculando el stake"})
+	}
+
+	return c.JSON(suggestion)
+}
+
+// placeBetError traduce los errores de validación de una apuesta a la respuesta HTTP
+func placeBetError(c *fiber.Ctx, err error) error {
+	// La cuota cambió: devolvemos el precio actual para que el usuario confirme
+	var oddsErr *OddsChangedError
+	if errors.As(err, &oddsErr) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   err.Error(),
+			"code":    "ODDS_CHANGED",
+			"details": oddsErr,
+		})
+	}
+	if errors.Is(err, ErrInsufficientFunds) || errors.Is(err, ErrInvalidBet) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": "Error interno al procesar apuesta"})
+}
+
 // ResolveBetRequest define qué esperamos recibir en el JSON
 type ResolveBetRequest struct {
-	Outcome string `json:"outcome"` // "WON" o "LOST"
+	Outcome string  `json:"outcome"` // "WON", "LOST", "VOID", "PUSH", "HALF_WON", "HALF_LOST" o "CASHOUT"
+	Amount  float64 `json:"amount"`  // Importe pagado, solo para CASHOUT
 }
 
-// ResolveBetHandler define el resultado de una apuesta.
+// ResolveBetHandler define el resultado de una apuesta (solo administradores).
+// Los usuarios cierran sus apuestas antes de tiempo con el flujo de cotización de cash-out.
 // @Router /api/bets/{id}/resolve [patch]
 func (h *Handler) ResolveBetHandler(c *fiber.Ctx) error {
 	betID := c.Params("id")
@@ -77,13 +148,18 @@ func (h *Handler) ResolveBetHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Outcome != "WON" && req.Outcome != "LOST" {
+	switch req.Outcome {
+	case StatusWon, StatusLost, StatusVoid, StatusPush, StatusHalfWon, StatusHalfLost, StatusCashout:
+	default:
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "El resultado (outcome) debe ser 'WON' o 'LOST'",
+			"error": "El resultado (outcome) debe ser 'WON', 'LOST', 'VOID', 'PUSH', 'HALF_WON', 'HALF_LOST' o 'CASHOUT'",
 		})
 	}
 
-	err := h.service.ResolveBet(betID, req.Outcome)
+	err := h.service.ResolveBet(betID, req.Outcome, req.Amount)
+	if errors.Is(err, ErrInvalidBet) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -96,6 +172,85 @@ func (h *Handler) ResolveBetHandler(c *fiber.Ctx) error {
 	})
 }
 
+// CashoutQuoteRequest define la fracción del stake abierto que se quiere cerrar
+type CashoutQuoteRequest struct {
+	Fraction float64 `json:"fraction"` // (0-1], por defecto 1 (cash-out total)
+}
+
+// CashoutQuoteHandler cotiza el cash-out de una apuesta pendiente.
+// @Router /api/bets/{id}/cashout/quote [post]
+func (h *Handler) CashoutQuoteHandler(c *fiber.Ctx) error {
+	userID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	betID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ID de apuesta inválido"})
+	}
+
+	var req CashoutQuoteRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Datos inválidos"})
+		}
+	}
+	if req.Fraction == 0 {
+		req.Fraction = 1
+	}
+
+	quote, err := h.service.QuoteCashout(userID, betID, req.Fraction)
+	if err != nil {
+		return cashoutError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"quote": quote})
+}
+
+// AcceptCashoutRequest referencia la oferta que el usuario acepta
+type AcceptCashoutRequest struct {
+	QuoteID string `json:"quote_id"`
+}
+
+// CashoutHandler acepta una oferta de cash-out y liquida (total o parcialmente) la apuesta.
+// @Router /api/bets/{id}/cashout [post]
+func (h *Handler) CashoutHandler(c *fiber.Ctx) error {
+	userID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	betID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ID de apuesta inválido"})
+	}
+
+	var req AcceptCashoutRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Datos inválidos"})
+	}
+	quoteID, err := uuid.Parse(req.QuoteID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Debes indicar una oferta (quote_id) válida"})
+	}
+
+	bet, err := h.service.AcceptCashout(userID, betID, quoteID)
+	if err != nil {
+		return cashoutError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Cash-out realizado con éxito",
+		"bet":     bet,
+	})
+}
+
+// cashoutError traduce los errores del flujo de cash-out a códigos HTTP
+func cashoutError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Apuesta u oferta no encontrada"})
+	case errors.Is(err, ErrInvalidBet), errors.Is(err, ErrCashoutUnavailable):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": "Error interno al procesar el cash-out"})
+}
+
 // GetBetsHandler obtiene el historial de apuestas
 // @Router /api/bets [get]
 func (h *Handler) GetBetsHandler(c *fiber.Ctx) error {
@@ -158,13 +313,155 @@ func (h *Handler) GetStatsHandler(c *fiber.Ctx) error {
 		topSport = stats.SportPerformance[0].SportKey
 	}
 
-	// 3. Generar el Tip de IA
-	tip := h.aiService.GenerateTip(stats.WinRate, stats.TotalBets, topSport, stats.TotalProfit)
-	stats.AiTip = tip
+	// 3. Generar el Tip de IA. Solo sustituye al tip de las reglas si respondió el modelo:
+	// el respaldo local del servicio de IA no aporta más que el motor de reglas.
+	tip, fromAI := h.aiService.GenerateTip(stats.WinRate, stats.TotalBets, topSport, stats.TotalProfit)
+	if fromAI {
+		stats.AiTip = tip
+		stats.Insights = append(stats.Insights, analytics.Insight{
+			Code:    "ai_tip",
+			Level:   analytics.LevelInfo,
+			Title:   "Consejo de la IA",
+			Message: tip,
+			Metrics: map[string]float64{
+				analytics.MetricWinRate:     stats.WinRate,
+				analytics.MetricTotalBets:   float64(stats.TotalBets),
+				analytics.MetricTotalProfit: stats.TotalProfit,
+			},
+			Source: analytics.InsightSourceAI,
+		})
+	}
 
 	return c.JSON(stats)
 }
 
+// GetBankrollHistoryHandler devuelve la evolución del bankroll con su máximo, drawdowns y rachas.
+// Query: bucket=day|week (por defecto day).
+// @Router /api/stats/bankroll-history [get]
+func (h *Handler) GetBankrollHistoryHandler(c *fiber.Ctx) error {
+	userID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	bucket := c.Query("bucket", analytics.BucketDay)
+	if bucket != analytics.BucketDay && bucket != analytics.BucketWeek {
+		return c.Status(400).JSON(fiber.Map{"error": "bucket debe ser 'day' o 'week'"})
+	}
+
+	history, err := h.service.GetBankrollHistory(userID, bucket)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error calculando la evolución del bankroll"})
+	}
+
+	return c.JSON(history)
+}
+
+// GetLuckReportHandler compara aciertos y profit reales con los esperados según las cuotas
+// (z-score e intervalo de confianza del 95%) para distinguir rachas de suerte de habilidad.
+// @Router /api/stats/luck [get]
+func (h *Handler) GetLuckReportHandler(c *fiber.Ctx) error {
+	userID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	report, err := h.service.GetLuckReport(userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error calculando el informe de suerte"})
+	}
+
+	return c.JSON(report)
+}
+
+// GetBreakdownHandler desglosa ROI, profit, win rate y volumen por liga, tramo de cuota,
+// día de la semana, hora y tamaño del stake.
+// Query: by=league,odds,weekday,hour,stake (por defecto todas), from y to (YYYY-MM-DD, to inclusive).
+// @Router /api/stats/breakdown [get]
+func (h *Handler) GetBreakdownHandler(c *fiber.Ctx) error {
+	userID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	var dimensions []string
+	if by := c.Query("by"); by != "" {
+		for _, dimension := range strings.Split(by, ",") {
+			dimensions = append(dimensions, strings.TrimSpace(dimension))
+		}
+	}
+
+	var filter BreakdownFilter
+	if from := c.Query("from"); from != "" {
+		day, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "'from' debe tener el formato YYYY-MM-DD"})
+		}
+		filter.From = &day
+	}
+	if to := c.Query("to"); to != "" {
+		day, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "'to' debe tener el formato YYYY-MM-DD"})
+		}
+		// 'to' es inclusive: se filtra hasta el inicio del día siguiente
+		end := day.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+
+	breakdown, err := h.service.GetBreakdown(userID, dimensions, filter)
+	if err != nil {
+		if errors.Is(err, ErrInvalidBreakdown) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Error calculando el desglose"})
+	}
+
+	return c.JSON(breakdown)
+}
+
+// SimulateBankrollHandler simula miles de trayectorias futuras remuestreando el historial
+// (Monte Carlo) y devuelve curvas de percentiles y probabilidades de duplicar y de ruina.
+// Query: bets (horizonte), paths, seed (misma semilla = mismo resultado) y ruin (fracción del bankroll, 0.1 por defecto).
+// @Router /api/stats/simulation [get]
+func (h *Handler) SimulateBankrollHandler(c *fiber.Ctx) error {
+	userID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	params := analytics.SimulationParams{
+		Paths:        c.QueryInt("paths", analytics.DefaultSimulationPaths),
+		Bets:         c.QueryInt("bets", analytics.DefaultSimulationBets),
+		Seed:         time.Now().UnixNano(),
+		RuinFraction: c.QueryFloat("ruin", analytics.DefaultRuinFraction),
+	}
+	if raw := c.Query("seed"); raw != "" {
+		seed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "La semilla (seed) debe ser un entero"})
+		}
+		params.Seed = seed
+	}
+
+	result, err := h.service.SimulateBankroll(userID, params)
+	if err != nil {
+		if errors.Is(err, analytics.ErrInsufficientHistory) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Error simulando el bankroll"})
+	}
+
+	return c.JSON(result)
+}
+
+// GetCalibrationHandler compara la probabilidad estimada por el usuario con su acierto real
+// por tramos, con Brier score y log loss. Query: buckets (10 por defecto).
+// @Router /api/stats/calibration [get]
+func (h *Handler) GetCalibrationHandler(c *fiber.Ctx) error {
+	userID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	buckets := c.QueryInt("buckets", analytics.DefaultCalibrationBuckets)
+	if buckets <= 0 || buckets > analytics.MaxCalibrationBuckets {
+		return c.Status(400).JSON(fiber.Map{"error": "buckets debe estar entre 1 y 20"})
+	}
+
+	report, err := h.service.GetCalibrationReport(userID, buckets)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error calculando la calibración"})
+	}
+
+	return c.JSON(report)
+}
+
 // GetTransactionsHandler obtiene el extracto bancario.
 // @Router /api/transactions [get]
 func (h *Handler) GetTransactionsHandler(c *fiber.Ctx) error {
@@ -192,19 +489,29 @@ type DashboardStatsResponse struct {
 	WinRate          float64     `json:"win_rate"`
 	TotalProfit      float64     `json:"total_profit"`
 	CurrentBankroll  float64     `json:"current_bankroll"`
+	AvgCLV           float64     `json:"avg_clv"`  // Closing Line Value medio (%)
+	CLVBets          int64       `json:"clv_bets"` // Apuestas con precio de cierre
 	AiTip            string      `json:"ai_tip"`
 	SportPerformance []SportStat `json:"sport_performance"`
+
+	// Insights son los consejos estructurados (reglas del asesor y, si está disponible, la IA)
+	Insights []analytics.Insight `json:"insights"`
 }
 
 type SportStat struct {
 	SportKey string  `json:"sport_key"`
 	Bets     int     `json:"bets"`
 	Profit   float64 `json:"profit"`
+	AvgCLV   float64 `json:"avg_clv"`
+	CLVBets  int     `json:"clv_bets"`
 }
 
 type ResolveMatchRequest struct {
-	MatchID string `json:"match_id"`
-	Winner  string `json:"winner"` // "HOME" o "AWAY"
+	MatchID   string                       `json:"match_id"`
+	Winner    string                       `json:"winner"`     // "HOME", "AWAY", "DRAW" o "VOID" (partido cancelado)
+	HomeScore int                          `json:"home_score"` // Opcional: necesario para liquidar hándicaps y totales
+	AwayScore int                          `json:"away_score"`
+	Periods   []market.PeriodResultRequest `json:"periods"` // Opcional: necesario para liquidar mercados de mapa
 }
 
 // SettleMatchHandler (Endpoint Admin)
@@ -219,12 +526,43 @@ func (h *Handler) SettleMatchHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID de partido inválido"})
 	}
 
-	if req.Winner != "HOME" && req.Winner != "AWAY" {
-		return c.Status(400).JSON(fiber.Map{"error": "El ganador debe ser HOME o AWAY"})
+	if req.Winner != "HOME" && req.Winner != "AWAY" && req.Winner != "DRAW" && req.Winner != StatusVoid {
+		return c.Status(400).JSON(fiber.Map{"error": "El ganador debe ser HOME, AWAY, DRAW o VOID"})
 	}
 
-	err = h.service.SettleMatch(matchUUID, req.Winner)
-	if err != nil {
+	result := &market.MatchResult{
+		MatchID:   matchUUID,
+		Winner:    req.Winner,
+		HomeScore: req.HomeScore,
+		AwayScore: req.AwayScore,
+	}
+	for _, period := range req.Periods {
+		winner := period.Winner
+		if winner == "" {
+			// Igual que al registrar resultados: el ganador del mapa se deduce del marcador
+			switch {
+			case period.HomeScore > period.AwayScore:
+				winner = "HOME"
+			case period.AwayScore > period.HomeScore:
+				winner = "AWAY"
+			default:
+				winner = "DRAW"
+			}
+		}
+		result.Periods = append(result.Periods, market.PeriodResult{
+			MatchID:   matchUUID,
+			Period:    period.Period,
+			Winner:    winner,
+			HomeScore: period.HomeScore,
+			AwayScore: period.AwayScore,
+		})
+	}
+
+	if err := h.service.SettleMatch(result); err != nil {
+		// Lo que se pudo resolver ya quedó liquidado; el resto espera un resultado completo
+		if errors.Is(err, ErrIncompleteResult) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -0,0 +1,117 @@
+package betting
+
+import (
+	"github.com/google/uuid"
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
+	"github.com/xnzperez/sports-analytics-backend/internal/market"
+)
+
+// GetLuckReport compara los aciertos y el profit reales del usuario con los esperados
+// según las cuotas tomadas. Solo cuentan las apuestas ganadas o perdidas: las anuladas,
+// push, cash-out y medias líneas no son un acierto/fallo binario.
+func (s *Service) GetLuckReport(userID uuid.UUID) (*analytics.LuckReport, error) {
+	bets, err := s.repo.GetResolvedBets(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pricer := newClosingPricer(s.repo)
+	samples := make([]analytics.LuckSample, 0, len(bets))
+	for _, bet := range bets {
+		if bet.Status != StatusWon && bet.Status != StatusLost {
+			continue
+		}
+		samples = append(samples, luckSample(bet, pricer))
+	}
+
+	report := analytics.BuildLuckReport(samples)
+	return &report, nil
+}
+
+// luckSample convierte una apuesta resuelta en una muestra para el informe de suerte.
+// La probabilidad de referencia es la justa (sin margen) del mercado de cierre, el más
+// eficiente; en una combinada, el producto de la de cada selección. Si falta el cierre de
+// algún lado del mercado se usa la implícita del cierre o de la cuota tomada, con margen.
+func luckSample(bet Bet, pricer *closingPricer) analytics.LuckSample {
+	sample := analytics.LuckSample{
+		Stake: bet.OpenStake(),
+		Odds:  bet.Odds,
+		Won:   bet.Status == StatusWon,
+	}
+
+	if probability, ok := pricer.fairProbability(bet.Legs); ok {
+		sample.Probability = probability
+		sample.MarginFree = true
+		return sample
+	}
+
+	reference := bet.Odds
+	if bet.ClosingOdds > 1 {
+		reference = bet.ClosingOdds
+	}
+	sample.Probability = analytics.ImpliedProbability(reference)
+	return sample
+}
+
+// closingPricer calcula probabilidades justas de cierre, cacheando partidos y líneas
+type closingPricer struct {
+	repo    *Repository
+	matches map[uuid.UUID]*market.MarketPricing
+	lines   map[uuid.UUID]*market.MarketPricing
+}
+
+func newClosingPricer(repo *Repository) *closingPricer {
+	return &closingPricer{
+		repo:    repo,
+		matches: make(map[uuid.UUID]*market.MarketPricing),
+		lines:   make(map[uuid.UUID]*market.MarketPricing),
+	}
+}
+
+// fairProbability multiplica la probabilidad justa de cierre de cada selección decisiva.
+// Las anuladas y push no cuentan (pagan 1.00). ok=false si alguna no se puede calcular.
+func (p *closingPricer) fairProbability(legs []BetLeg) (float64, bool) {
+	probability, counted := 1.0, 0
+	for _, leg := range legs {
+		switch leg.Status {
+		case StatusVoid, StatusPush:
+			continue
+		case StatusWon, StatusLost:
+		default:
+			return 0, false
+		}
+
+		price := p.pricing(leg).For(leg.Selection)
+		if price == nil || price.FairProbability <= 0 {
+			return 0, false
+		}
+		probability *= price.FairProbability
+		counted++
+	}
+	return probability, counted > 0
+}
+
+// pricing devuelve el mercado de cierre sin margen de la selección (nil si no se conoce)
+func (p *closingPricer) pricing(leg BetLeg) *market.MarketPricing {
+	if leg.MarketLineID != nil {
+		if pricing, ok := p.lines[*leg.MarketLineID]; ok {
+			return pricing
+		}
+		var pricing *market.MarketPricing
+		if line, err := p.repo.GetMarketLine(*leg.MarketLineID); err == nil {
+			pricing = line.ClosingPriceMarket()
+		}
+		p.lines[*leg.MarketLineID] = pricing
+		return pricing
+	}
+
+	if pricing, ok := p.matches[leg.MatchID]; ok {
+		return pricing
+	}
+	var pricing *market.MarketPricing
+	if match, err := p.repo.GetMatchByID(leg.MatchID); err == nil {
+		pricing = match.ClosingPriceMarket()
+	}
+	p.matches[leg.MatchID] = pricing
+	return pricing
+}
@@ -0,0 +1,149 @@
+package betting
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
+	"github.com/xnzperez/sports-analytics-backend/internal/market"
+)
+
+// BetPreview es la simulación de una apuesta antes de confirmarla:
+// valor esperado según las cuotas justas del mercado, stake sugerido e impacto en el bankroll.
+type BetPreview struct {
+	Title    string       `json:"title"`
+	SportKey string       `json:"sport_key"`
+	Odds     float64      `json:"odds"` // Cuota total validada contra el mercado
+	Legs     []LegPreview `json:"legs"`
+
+	ImpliedProbability float64 `json:"implied_probability"` // 1 / cuota
+	FairProbability    float64 `json:"fair_probability"`    // Sin margen (producto de las selecciones)
+	ExpectedValue      float64 `json:"expected_value"`      // Ganancia esperada por unidad apostada
+	PositiveEV         bool    `json:"positive_ev"`
+
+	KellyFraction  float64 `json:"kelly_fraction"`  // Fracción de Kelly completa del bankroll
+	SuggestedStake float64 `json:"suggested_stake"` // Stake según la configuración de staking del usuario
+
+	Stake    float64        `json:"stake"` // Stake simulado (el enviado o, si es 0, el sugerido)
+	Bankroll BankrollImpact `json:"bankroll"`
+}
+
+// LegPreview es el análisis de una selección
+type LegPreview struct {
+	MatchID            uuid.UUID  `json:"match_id"`
+	MarketLineID       *uuid.UUID `json:"market_line_id,omitempty"`
+	Selection          string     `json:"selection"`
+	TeamName           string     `json:"team_name"`
+	Odds               float64    `json:"odds"`
+	ImpliedProbability float64    `json:"implied_probability"`
+	FairProbability    float64    `json:"fair_probability"`
+	FairOdds           float64    `json:"fair_odds"`
+}
+
+// BankrollImpact muestra cómo quedaría el saldo con la apuesta
+type BankrollImpact struct {
+	Current         float64 `json:"current"`
+	StakePct        float64 `json:"stake_pct"` // % del bankroll arriesgado
+	AfterPlacement  float64 `json:"after_placement"`
+	PotentialPayout float64 `json:"potential_payout"`
+	IfWon           float64 `json:"if_won"`
+	IfLost          float64 `json:"if_lost"`
+	SufficientFunds bool    `json:"sufficient_funds"`
+}
+
+// PreviewBet valida la apuesta igual que PlaceBet (mercado, estado del partido y cuota)
+// y calcula su valor esperado sin descontar saldo ni guardar nada.
+func (s *Service) PreviewBet(userID uuid.UUID, req PlaceBetRequest) (*BetPreview, error) {
+	// 1. Misma validación que PlaceBet
+	legs, _, err := s.buildLegs(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Probabilidad justa de cada selección (las combinadas se asumen independientes)
+	preview := &BetPreview{
+		Title:    req.Title,
+		SportKey: req.SportKey,
+		Odds:     req.Odds,
+		Legs:     make([]LegPreview, 0, len(legs)),
+	}
+	fairProbability := 1.0
+	for _, leg := range legs {
+		price, err := s.fairPrice(leg)
+		if err != nil {
+			return nil, err
+		}
+		fairProbability *= price.FairProbability
+
+		preview.Legs = append(preview.Legs, LegPreview{
+			MatchID:            leg.MatchID,
+			MarketLineID:       leg.MarketLineID,
+			Selection:          leg.Selection,
+			TeamName:           leg.TeamName,
+			Odds:               leg.Odds,
+			ImpliedProbability: analytics.ImpliedProbability(leg.Odds),
+			FairProbability:    price.FairProbability,
+			FairOdds:           price.FairOdds,
+		})
+	}
+
+	// 3. Valor esperado y Kelly
+	preview.ImpliedProbability = analytics.ImpliedProbability(req.Odds)
+	preview.FairProbability = fairProbability
+	preview.ExpectedValue = analytics.ExpectedValue(fairProbability, req.Odds)
+	preview.PositiveEV = preview.ExpectedValue > 0
+	preview.KellyFraction = analytics.KellyFraction(fairProbability, req.Odds)
+
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	bankroll := user.Bankroll
+	preview.SuggestedStake = analytics.SuggestStake(bankroll, req.Odds, fairProbability, user.Staking.ToAnalytics()).Stake
+
+	// 4. Impacto en el bankroll
+	stake := req.StakeUnits
+	if stake <= 0 {
+		stake = preview.SuggestedStake
+	}
+	preview.Stake = stake
+
+	payout := stake * req.Odds
+	preview.Bankroll = BankrollImpact{
+		Current:         bankroll,
+		AfterPlacement:  bankroll - stake,
+		PotentialPayout: payout,
+		IfWon:           bankroll - stake + payout,
+		IfLost:          bankroll - stake,
+		SufficientFunds: bankroll >= stake,
+	}
+	if bankroll > 0 {
+		preview.Bankroll.StakePct = stake / bankroll * 100
+	}
+
+	return preview, nil
+}
+
+// fairPrice busca el precio sin margen de una selección en su mercado (ganador o línea)
+func (s *Service) fairPrice(leg BetLeg) (*market.SelectionPrice, error) {
+	var pricing *market.MarketPricing
+	if leg.MarketLineID != nil {
+		line, err := s.repo.GetMarketLine(*leg.MarketLineID)
+		if err != nil {
+			return nil, err
+		}
+		pricing = line.PriceMarket()
+	} else {
+		match, err := s.repo.GetMatchByID(leg.MatchID)
+		if err != nil {
+			return nil, err
+		}
+		pricing = match.PriceMarket()
+	}
+
+	price := pricing.For(leg.Selection)
+	if price == nil {
+		return nil, fmt.Errorf("%w: no hay precio justo para '%s'", ErrInvalidBet, leg.Selection)
+	}
+	return price, nil
+}
@@ -2,10 +2,13 @@ package betting
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/xnzperez/sports-analytics-backend/internal/auth"
+	"github.com/xnzperez/sports-analytics-backend/internal/market"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -46,8 +49,9 @@ func (r *Repository) CreateBet(tx *gorm.DB, bet *Bet) error {
 	return tx.Create(bet).Error
 }
 
-// ResolveBet maneja la lógica de ganar/perder y actualiza el saldo atómicamente
-func (r *Repository) ResolveBet(betIDStr string, outcome string) error {
+// ResolveBet maneja la lógica de ganar/perder/anular y actualiza el saldo atómicamente.
+// cashoutAmount solo se usa cuando outcome es CASHOUT.
+func (r *Repository) ResolveBet(betIDStr string, outcome string, cashoutAmount float64) error {
 
 	// 0. Convertir string a UUID (Validación inicial)
 	betID, err := uuid.Parse(betIDStr)
@@ -64,44 +68,305 @@ func (r *Repository) ResolveBet(betIDStr string, outcome string) error {
 		}
 
 		// 2. Validación
-		if bet.Status != "pending" {
+		if bet.Status != StatusPending {
 			return errors.New("esta apuesta ya ha sido resuelta anteriormente")
 		}
 
-		// 3. Actualizar apuesta
-		now := time.Now()
-		bet.Status = outcome
-		bet.ResultedAt = &now
-		if err := tx.Save(&bet).Error; err != nil {
+		// 3. Actualizar apuesta y pagar
+		payout := payoutFor(&bet, outcome)
+		if outcome == StatusCashout {
+			// Un cash-out manual nunca puede pagar más de lo que pagaría la apuesta ganada
+			if maxPayout := bet.OpenStake() * bet.Odds; cashoutAmount > maxPayout {
+				return fmt.Errorf("%w: el cash-out no puede superar %.2f (stake abierto x cuota)", ErrInvalidBet, maxPayout)
+			}
+			payout = cashoutAmount
+		} else {
+			bet.SettlementFactor = payoutFactor(outcome, bet.Odds)
+		}
+		return r.settleBetTx(tx, &bet, outcome, payout)
+	})
+}
+
+// SettleMatchLegs resuelve todas las selecciones pendientes de un partido y liquida
+// las apuestas padre cuyo resultado ya sea definitivo. Devuelve cuántas apuestas se liquidaron
+// y cuántas selecciones siguen pendientes porque el resultado no basta para resolverlas
+// (hándicaps y totales sin marcador, ganadores de mapa sin mapas).
+func (r *Repository) SettleMatchLegs(result *market.MatchResult) (settled int, unresolved int, err error) {
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		// 1. Bloquear las selecciones pendientes del partido
+		var legs []BetLeg
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("match_id = ? AND status = ?", result.MatchID, StatusPending).
+			Find(&legs).Error; err != nil {
 			return err
 		}
 
-		// 4. Si ganó, pagar y registrar transacción
-		if outcome == "WON" {
-			payout := bet.StakeUnits * bet.Odds
+		// 2. Resolver cada selección individualmente
+		// El tipo de mercado (dos o tres vías) decide qué pasa con HOME/AWAY si hay empate
+		var match market.Match
+		threeWay := tx.First(&match, "id = ?", result.MatchID).Error == nil && match.ThreeWay()
+
+		now := time.Now()
+		affected := make(map[uuid.UUID]bool)
+		for _, leg := range legs {
+			status, ok := legResult(leg, result, threeWay)
+			if !ok {
+				unresolved++
+				continue
+			}
+			if err := tx.Model(&BetLeg{}).Where("id = ?", leg.ID).Updates(map[string]interface{}{
+				"status":      status,
+				"resulted_at": now,
+			}).Error; err != nil {
+				return err
+			}
+			affected[leg.BetID] = true
+		}
 
-			// A. Actualizar Saldo Usuario
-			// bet.UserID ya es UUID, GORM lo maneja bien en el Where
-			if err := tx.Model(&auth.User{}).Where("id = ?", bet.UserID).
-				Update("bankroll", gorm.Expr("bankroll + ?", payout)).Error; err != nil {
+		// 3. Consolidar las apuestas padre
+		for betID := range affected {
+			var bet Bet
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Preload("Legs").
+				First(&bet, "id = ?", betID).Error; err != nil {
 				return err
 			}
 
-			// B. Registrar Transacción (Ledger)
-			transaction := &Transaction{
-				UserID:      bet.UserID, // UUID directo
-				Amount:      payout,
-				Type:        "BET_PAYOUT",
-				Description: "Ganancia apuesta: " + bet.Title,
-				ReferenceID: &bet.ID, // Puntero a UUID (*uuid.UUID)
+			// Una combinada puede haberse perdido antes por otra selección
+			if bet.Status != StatusPending {
+				continue
 			}
-			if err := tx.Create(transaction).Error; err != nil {
+
+			status, factor, done := rollupLegs(bet.Legs)
+			if !done {
+				continue
+			}
+
+			// La cuota tomada se conserva; el factor refleja selecciones anuladas o a medias
+			bet.SettlementFactor = factor
+
+			if err := r.settleBetTx(tx, &bet, status, bet.OpenStake()*factor); err != nil {
 				return err
 			}
+			settled++
 		}
 
 		return nil
 	})
+
+	return settled, unresolved, err
+}
+
+// settleBetTx marca la apuesta como resuelta y abona el pago correspondiente.
+// Debe llamarse dentro de una transacción con la fila de la apuesta ya bloqueada.
+func (r *Repository) settleBetTx(tx *gorm.DB, bet *Bet, outcome string, payout float64) error {
+	// 1. Actualizar apuesta (sin tocar las selecciones asociadas)
+	// Payout acumula lo ya cobrado en cash-outs parciales
+	now := time.Now()
+	bet.Status = outcome
+	bet.ResultedAt = &now
+	bet.Payout += payout
+	if outcome == StatusCashout {
+		bet.ClosedStake = bet.StakeUnits
+	}
+	if err := tx.Omit(clause.Associations).Save(bet).Error; err != nil {
+		return err
+	}
+
+	// Apuesta perdida (o cashout a cero): el stake ya se descontó al apostar
+	if payout <= 0 {
+		return nil
+	}
+
+	// 2. Tipo de movimiento según el resultado
+	var txType, description string
+	switch outcome {
+	case StatusWon, StatusHalfWon:
+		txType = "BET_PAYOUT"
+		description = "Ganancia apuesta: " + bet.Title
+	case StatusHalfLost:
+		txType = "BET_REFUND"
+		description = "Reembolso parcial apuesta (" + outcome + "): " + bet.Title
+	case StatusVoid, StatusPush:
+		txType = "BET_REFUND"
+		description = "Reembolso apuesta (" + outcome + "): " + bet.Title
+	case StatusCashout:
+		txType = "BET_CASHOUT"
+		description = "Cash-out apuesta: " + bet.Title
+	default:
+		return errors.New("resultado de apuesta desconocido: " + outcome)
+	}
+
+	return r.creditUserTx(tx, bet, payout, txType, description)
+}
+
+// creditUserTx suma un importe al bankroll y lo registra en el ledger
+func (r *Repository) creditUserTx(tx *gorm.DB, bet *Bet, amount float64, txType, description string) error {
+	// A. Actualizar Saldo Usuario
+	if err := tx.Model(&auth.User{}).Where("id = ?", bet.UserID).
+		Update("bankroll", gorm.Expr("bankroll + ?", amount)).Error; err != nil {
+		return err
+	}
+
+	// B. Registrar Transacción (Ledger)
+	transaction := &Transaction{
+		UserID:      bet.UserID,
+		Amount:      amount,
+		Type:        txType,
+		Description: description,
+		ReferenceID: &bet.ID,
+	}
+	return tx.Create(transaction).Error
+}
+
+// GetBetByID obtiene una apuesta con sus selecciones
+func (r *Repository) GetBetByID(betID uuid.UUID) (*Bet, error) {
+	var bet Bet
+	if err := r.db.Preload("Legs").First(&bet, "id = ?", betID).Error; err != nil {
+		return nil, err
+	}
+	return &bet, nil
+}
+
+// CreateCashoutQuote guarda una oferta de cash-out
+func (r *Repository) CreateCashoutQuote(quote *CashoutQuote) error {
+	return r.db.Create(quote).Error
+}
+
+// AcceptCashout ejecuta una oferta de cash-out vigente de forma atómica.
+// Si la oferta cierra todo el stake abierto, la apuesta queda como CASHOUT;
+// si es parcial, la apuesta sigue pendiente con el stake restante.
+func (r *Repository) AcceptCashout(userID, betID, quoteID uuid.UUID) (*Bet, error) {
+	var bet Bet
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// 1. Bloquear la oferta y validarla
+		var quote CashoutQuote
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&quote, "id = ? AND bet_id = ? AND user_id = ?", quoteID, betID, userID).Error; err != nil {
+			return err
+		}
+		if quote.AcceptedAt != nil {
+			return fmt.Errorf("%w: la oferta ya fue utilizada", ErrCashoutUnavailable)
+		}
+		now := time.Now()
+		if now.After(quote.ExpiresAt) {
+			return fmt.Errorf("%w: la oferta ha expirado, solicita una nueva", ErrCashoutUnavailable)
+		}
+
+		// 2. Bloquear la apuesta y comprobar que nada cambió desde la cotización
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bet, "id = ?", betID).Error; err != nil {
+			return err
+		}
+		if bet.UserID != userID || bet.Status != StatusPending {
+			return fmt.Errorf("%w: la apuesta ya no está pendiente", ErrCashoutUnavailable)
+		}
+		if math.Abs(bet.OpenStake()-quote.OpenStake) > 0.001 {
+			return fmt.Errorf("%w: el stake abierto cambió, solicita una nueva oferta", ErrCashoutUnavailable)
+		}
+
+		// 3. Marcar la oferta como aceptada
+		if err := tx.Model(&quote).Update("accepted_at", now).Error; err != nil {
+			return err
+		}
+
+		// 4A. Cash-out total: se liquida la apuesta
+		if quote.Stake >= bet.OpenStake() {
+			return r.settleBetTx(tx, &bet, StatusCashout, quote.Amount)
+		}
+
+		// 4B. Cash-out parcial: se cierra una parte del stake y se paga
+		bet.ClosedStake += quote.Stake
+		bet.Payout += quote.Amount
+		if err := tx.Omit(clause.Associations).Save(&bet).Error; err != nil {
+			return err
+		}
+		if quote.Amount <= 0 {
+			return nil
+		}
+		return r.creditUserTx(tx, &bet, quote.Amount, "BET_CASHOUT", "Cash-out parcial apuesta: "+bet.Title)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &bet, nil
+}
+
+// AttachClosingOdds guarda la cuota de cierre en las selecciones de un partido y
+// recalcula el CLV de sus apuestas. Devuelve cuántas apuestas obtuvieron CLV.
+func (r *Repository) AttachClosingOdds(match *market.Match) (int, error) {
+	updated := 0
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// 1. Selecciones del partido que aún no tienen precio de cierre
+		var legs []BetLeg
+		if err := tx.Where("match_id = ? AND closing_odds = 0", match.ID).Find(&legs).Error; err != nil {
+			return err
+		}
+
+		affected := make(map[uuid.UUID]bool)
+		for _, leg := range legs {
+			closing := match.ClosingOddsFor(leg.Selection)
+			if leg.MarketLineID != nil {
+				closing = 0
+				for _, line := range match.Lines {
+					if line.ID == *leg.MarketLineID {
+						closing = line.ClosingOddsFor(leg.Selection)
+					}
+				}
+			}
+			if closing <= 0 {
+				continue
+			}
+			if err := tx.Model(&BetLeg{}).Where("id = ?", leg.ID).Update("closing_odds", closing).Error; err != nil {
+				return err
+			}
+			affected[leg.BetID] = true
+		}
+
+		// 2. Recalcular el CLV de cada apuesta con todas sus selecciones cerradas
+		for betID := range affected {
+			var bet Bet
+			if err := tx.Preload("Legs").First(&bet, "id = ?", betID).Error; err != nil {
+				return err
+			}
+
+			closingOdds, clv, ok := clvFromLegs(bet.Legs)
+			if !ok {
+				continue
+			}
+			if err := tx.Model(&Bet{}).Where("id = ?", bet.ID).Updates(map[string]interface{}{
+				"closing_odds": closingOdds,
+				"clv":          clv,
+			}).Error; err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+
+	return updated, err
+}
+
+// GetMatchByID obtiene un partido del mercado sincronizado
+func (r *Repository) GetMatchByID(matchID uuid.UUID) (*market.Match, error) {
+	var match market.Match
+	if err := r.db.First(&match, "id = ?", matchID).Error; err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// GetMarketLine obtiene una línea de hándicap o total del mercado
+func (r *Repository) GetMarketLine(lineID uuid.UUID) (*market.MarketLine, error) {
+	var line market.MarketLine
+	if err := r.db.First(&line, "id = ?", lineID).Error; err != nil {
+		return nil, err
+	}
+	return &line, nil
 }
 
 func (r *Repository) GetBets(f BetFilters) ([]Bet, int64, error) {
@@ -126,7 +391,7 @@ func (r *Repository) GetBets(f BetFilters) ([]Bet, int64, error) {
 
 	// 4. Aplicar Paginación y Ordenamiento
 	offset := (f.Page - 1) * f.Limit
-	err := query.Limit(f.Limit).Offset(offset).Order("created_at desc").Find(&bets).Error
+	err := query.Preload("Legs").Limit(f.Limit).Offset(offset).Order("created_at desc").Find(&bets).Error
 
 	return bets, total, err
 }
@@ -137,6 +402,10 @@ type RawStats struct {
 	Won           int64
 	Lost          int64
 	Pending       int64
+	Void          int64 // VOID + PUSH
+	Cashout       int64
+	CLVBets       int64 // Apuestas con precio de cierre
+	AvgCLV        float64
 	TotalWagered  float64
 	TotalReturned float64
 }
@@ -152,8 +421,15 @@ func (r *Repository) GetRawStats(userID uuid.UUID) (*RawStats, error) {
             COUNT(*) FILTER (WHERE status = 'WON') as won,
             COUNT(*) FILTER (WHERE status = 'LOST') as lost,
             COUNT(*) FILTER (WHERE status = 'pending') as pending,
+            COUNT(*) FILTER (WHERE status IN ('VOID', 'PUSH')) as void,
+            COUNT(*) FILTER (WHERE status = 'CASHOUT') as cashout,
+            COUNT(clv) as clv_bets,
+            COALESCE(AVG(clv), 0) as avg_clv,
             COALESCE(SUM(stake_units), 0) as total_wagered,
-            COALESCE(SUM(CASE WHEN status = 'WON' THEN stake_units * odds ELSE 0 END), 0) as total_returned
+            COALESCE(SUM(CASE
+                WHEN status = 'WON' AND payout = 0 THEN stake_units * odds
+                WHEN status <> 'pending' THEN payout
+                ELSE 0 END), 0) as total_returned
         `).
 		Where("user_id = ?", userID).
 		Scan(&stats).Error
@@ -165,6 +441,73 @@ func (r *Repository) GetRawStats(userID uuid.UUID) (*RawStats, error) {
 	return &stats, nil
 }
 
+// SportCLV resume el Closing Line Value de un deporte
+type SportCLV struct {
+	SportKey string  `json:"sport_key"`
+	Bets     int64   `json:"bets"`
+	AvgCLV   float64 `json:"avg_clv"`
+}
+
+// GetCLVBySport calcula el CLV medio por deporte (solo apuestas con precio de cierre)
+func (r *Repository) GetCLVBySport(userID uuid.UUID) ([]SportCLV, error) {
+	var rows []SportCLV
+	err := r.db.Model(&Bet{}).
+		Select("sport_key, COUNT(*) as bets, AVG(clv) as avg_clv").
+		Where("user_id = ? AND clv IS NOT NULL", userID).
+		Group("sport_key").
+		Order("sport_key").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// MarketStat resume el rendimiento de un tipo de mercado.
+// Market: moneyline, draw (empate a tres vías), spread, total, map_moneyline, map_spread, map_total o parlay.
+type MarketStat struct {
+	Market   string  `json:"market"`
+	Bets     int64   `json:"bets"`
+	Won      int64   `json:"won"`
+	Wagered  float64 `json:"wagered"`
+	Returned float64 `json:"returned"`
+	Profit   float64 `json:"profit"`
+	ROI      float64 `json:"roi"`
+}
+
+// GetMarketBreakdown agrupa las apuestas resueltas por tipo de mercado.
+// Las apuestas sin selecciones (antiguas) cuentan como moneyline.
+func (r *Repository) GetMarketBreakdown(userID uuid.UUID) ([]MarketStat, error) {
+	var rows []MarketStat
+	err := r.db.Table("bets b").
+		Select(`
+            CASE
+                WHEN b.is_parlay THEN 'parlay'
+                WHEN l.period > 0 THEN 'map_' || l.market_type
+                WHEN l.selection = 'DRAW' THEN 'draw'
+                ELSE COALESCE(l.market_type, 'moneyline') END as market,
+            COUNT(*) as bets,
+            COUNT(*) FILTER (WHERE b.status = 'WON') as won,
+            COALESCE(SUM(b.stake_units), 0) as wagered,
+            COALESCE(SUM(CASE
+                WHEN b.status = 'WON' AND b.payout = 0 THEN b.stake_units * b.odds
+                ELSE b.payout END), 0) as returned
+        `).
+		Joins("LEFT JOIN bet_legs l ON l.bet_id = b.id AND NOT b.is_parlay").
+		Where("b.user_id = ? AND b.status <> ?", userID, StatusPending).
+		Group("market").
+		Order("market").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rows {
+		rows[i].Profit = rows[i].Returned - rows[i].Wagered
+		if rows[i].Wagered > 0 {
+			rows[i].ROI = rows[i].Profit / rows[i].Wagered * 100
+		}
+	}
+	return rows, nil
+}
+
 // GetTransactions obtiene el historial financiero paginado
 func (r *Repository) GetTransactions(userID uuid.UUID, page, limit int) ([]Transaction, int64, error) {
 	var transactions []Transaction
@@ -185,6 +528,25 @@ func (r *Repository) GetTransactions(userID uuid.UUID, page, limit int) ([]Trans
 	return transactions, total, err
 }
 
+// GetLedger obtiene todos los movimientos del usuario en orden cronológico
+func (r *Repository) GetLedger(userID uuid.UUID) ([]Transaction, error) {
+	var transactions []Transaction
+	err := r.db.Where("user_id = ?", userID).
+		Order("created_at asc").
+		Find(&transactions).Error
+	return transactions, err
+}
+
+// GetResolvedBets obtiene las apuestas ya resueltas del usuario en el orden en que se liquidaron
+func (r *Repository) GetResolvedBets(userID uuid.UUID) ([]Bet, error) {
+	var bets []Bet
+	err := r.db.Preload("Legs").
+		Where("user_id = ? AND status <> ?", userID, StatusPending).
+		Order("COALESCE(resulted_at, created_at) asc").
+		Find(&bets).Error
+	return bets, err
+}
+
 func (r *Repository) GetUserByID(userID uuid.UUID) (*auth.User, error) {
 	var user auth.User
 	// Buscamos en la tabla users usando el modelo de auth
@@ -194,10 +556,19 @@ func (r *Repository) GetUserByID(userID uuid.UUID) (*auth.User, error) {
 	return &user, nil
 }
 
-// GetPendingBets obtiene todas las apuestas que aún no han finalizado
+// GetPendingBets obtiene todas las apuestas que aún no han finalizado (con sus selecciones)
 func (r *Repository) GetPendingBets() ([]Bet, error) {
 	var bets []Bet
 	// Buscamos status = 'pending'
-	result := r.db.Where("status = ?", "pending").Find(&bets)
+	result := r.db.Preload("Legs").Where("status = ?", StatusPending).Find(&bets)
+	return bets, result.Error
+}
+
+// GetPendingBetsWithoutLegs obtiene apuestas pendientes antiguas que solo guardan el partido en 'details'
+func (r *Repository) GetPendingBetsWithoutLegs() ([]Bet, error) {
+	var bets []Bet
+	result := r.db.Where("status = ?", StatusPending).
+		Where("NOT EXISTS (SELECT 1 FROM bet_legs WHERE bet_legs.bet_id = bets.id)").
+		Find(&bets)
 	return bets, result.Error
 }
@@ -2,12 +2,14 @@ package betting
 
 import (
 	"errors"
+	"fmt"
 
 	"encoding/json"
 	"log"
 
 	"github.com/google/uuid"
 	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
+	"github.com/xnzperez/sports-analytics-backend/internal/market"
 	"gorm.io/gorm"
 )
 
@@ -19,6 +21,13 @@ func NewService(repo *Repository) *Service {
 	return &Service{repo: repo}
 }
 
+// Errores de validación que el Handler traduce a un 400
+var (
+	ErrInsufficientFunds = errors.New("saldo insuficiente para realizar esta apuesta")
+	ErrInvalidBet        = errors.New("apuesta inválida")
+	ErrIncompleteResult  = errors.New("el resultado no basta para liquidar todas las selecciones")
+)
+
 // PlaceBetRequest es el JSON que recibiremos del Frontend
 type PlaceBetRequest struct {
 	Title      string  `json:"title"`
@@ -28,15 +37,37 @@ type PlaceBetRequest struct {
 	IsParlay   bool    `json:"is_parlay"`
 	UserNotes  string  `json:"user_notes"`
 
+	// EstimatedProbability es la probabilidad de acierto que estima el usuario (0-1, opcional)
+	EstimatedProbability *float64 `json:"estimated_probability"`
+
 	// CAMBIO AQUÍ: Usar map[string]interface{} es más seguro para lo que envía Zod
 	Details map[string]interface{} `json:"details"`
+
+	// Legs define las selecciones de una combinada. Si viene vacío, la apuesta
+	// es simple y el partido se toma de details.match_id (y details.market_line_id
+	// para hándicaps y totales).
+	Legs []LegRequest `json:"legs"`
+}
+
+// LegRequest es una selección individual dentro de una combinada
+type LegRequest struct {
+	MatchID      string  `json:"match_id"`
+	MarketLineID string  `json:"market_line_id,omitempty"` // Vacío = ganador del partido
+	Selection    string  `json:"selection"`                // "HOME", "AWAY", "DRAW", "OVER" o "UNDER"
+	Odds         float64 `json:"odds"`                     // Cuota vista por el cliente (opcional)
 }
 
 // PlaceBet maneja la creación de la apuesta y el descuento de saldo
 func (s *Service) PlaceBet(userID uuid.UUID, req PlaceBetRequest) (*Bet, error) {
+	// 0. Validar contra el mercado y construir las selecciones antes de tocar el saldo
+	legs, ref, err := s.buildLegs(&req)
+	if err != nil {
+		return nil, err
+	}
+
 	var newBet *Bet
 
-	err := s.repo.RunTransaction(func(tx *gorm.DB) error {
+	err = s.repo.RunTransaction(func(tx *gorm.DB) error {
 		// 1. Bloqueo y obtención de usuario (Anti-Race Condition)
 		user, err := s.repo.GetUserBalanceForUpdate(tx, userID)
 		if err != nil {
@@ -45,7 +76,7 @@ func (s *Service) PlaceBet(userID uuid.UUID, req PlaceBetRequest) (*Bet, error)
 
 		// 2. Verificar Fondos
 		if user.Bankroll < req.StakeUnits {
-			return errors.New("saldo insuficiente para realizar esta apuesta")
+			return ErrInsufficientFunds
 		}
 
 		// 3. Descontar Saldo
@@ -54,38 +85,34 @@ func (s *Service) PlaceBet(userID uuid.UUID, req PlaceBetRequest) (*Bet, error)
 			return err
 		}
 
-		// 4. Preparar Datos (JSON y ExternalID)
+		// 4. Preparar Datos (JSON)
+		// El external_id ya no se toma del cliente: viene del partido validado
 		detailsJSON := "{}"
-		var externalID string
-
-		// Extraemos datos clave del mapa genérico para indexarlos
 		if req.Details != nil {
-			// Intenta sacar el external_id para guardarlo en la columna indexada
-			if val, ok := req.Details["external_id"].(string); ok {
-				externalID = val
-			}
-
 			bytes, err := json.Marshal(req.Details)
 			if err == nil {
 				detailsJSON = string(bytes)
 			}
 		}
 
-		// 5. Crear la Apuesta
+		// 5. Crear la Apuesta (las selecciones se insertan junto a ella)
 		newBet = &Bet{
 			UserID:     userID,
 			Title:      req.Title,
 			SportKey:   req.SportKey,
 			StakeUnits: req.StakeUnits,
 			Odds:       req.Odds,
-			IsParlay:   req.IsParlay,
-			Status:     "pending",
+			IsParlay:   len(legs) > 1,
+			Status:     StatusPending,
 			Details:    detailsJSON,
 			UserNotes:  req.UserNotes,
+			Legs:       legs,
+
+			EstimatedProbability: req.EstimatedProbability,
 
 			// --- OPTIMIZACIÓN DE ESCALABILIDAD ---
-			ExternalID: externalID, // Guardamos el ID real aquí
-			Provider:   "pinnacle", // Asumimos pinnacle por defecto
+			ExternalID: ref.ExternalID, // ID real del partido en el proveedor
+			Provider:   ref.Provider,   // Fuente real del mercado (pinnacle, fixture...)
 			// -------------------------------------
 		}
 
@@ -119,17 +146,21 @@ func (s *Service) PlaceBet(userID uuid.UUID, req PlaceBetRequest) (*Bet, error)
 }
 
 // ResolveBet conecta el Handler con el Repository para finalizar una apuesta.
-func (s *Service) ResolveBet(betID string, outcome string) error {
+// cashoutAmount es el importe pagado cuando el resultado es CASHOUT (se ignora en el resto).
+func (s *Service) ResolveBet(betID string, outcome string, cashoutAmount float64) error {
 	if betID == "" {
 		return errors.New("el ID de la apuesta es obligatorio")
 	}
-	return s.repo.ResolveBet(betID, outcome)
+	if outcome == StatusCashout && cashoutAmount < 0 {
+		return fmt.Errorf("%w: el importe de cash-out no puede ser negativo", ErrInvalidBet)
+	}
+	return s.repo.ResolveBet(betID, outcome, cashoutAmount)
 }
 
 // BetFilters define los criterios de búsqueda
 type BetFilters struct {
 	UserID   uuid.UUID
-	Status   string // "pending", "WON", "LOST"
+	Status   string // "pending", "WON", "LOST", "VOID", "PUSH", "CASHOUT"
 	SportKey string // "cs2", "nba"
 	Page     int
 	Limit    int
@@ -172,6 +203,8 @@ type StatsResponse struct {
 	TotalWon     int64 `json:"total_won"`
 	TotalLost    int64 `json:"total_lost"`
 	TotalPending int64 `json:"total_pending"`
+	TotalVoid    int64 `json:"total_void"`    // Anuladas o push (stake devuelto)
+	TotalCashout int64 `json:"total_cashout"` // Cerradas con cash-out
 
 	WinRate float64 `json:"win_rate"` // % de aciertos
 
@@ -179,6 +212,14 @@ type StatsResponse struct {
 	TotalReturned float64 `json:"total_returned"` // Total recibido (ganancias + stake devuelto)
 	NetProfit     float64 `json:"net_profit"`     // Ganancia/Pérdida neta
 	ROI           float64 `json:"roi"`            // Retorno de Inversión (%)
+
+	// Closing Line Value: cuánto mejor (o peor) que el cierre apostó el usuario
+	CLVBets    int64      `json:"clv_bets"`     // Apuestas con precio de cierre
+	AvgCLV     float64    `json:"avg_clv"`      // CLV medio (%)
+	CLVBySport []SportCLV `json:"clv_by_sport"` // CLV medio por deporte
+
+	// Rendimiento por tipo de mercado (ganador, hándicap, total, mapas, combinadas)
+	ByMarket []MarketStat `json:"by_market"`
 }
 
 // GetUserStats calcula las estadísticas financieras y de rendimiento
@@ -195,6 +236,8 @@ func (s *Service) GetUserStats(userID uuid.UUID) (*StatsResponse, error) {
 		TotalWon:     stats.Won,
 		TotalLost:    stats.Lost,
 		TotalPending: stats.Pending,
+		TotalVoid:    stats.Void,
+		TotalCashout: stats.Cashout,
 		TotalWagered: stats.TotalWagered,
 	}
 
@@ -217,6 +260,22 @@ func (s *Service) GetUserStats(userID uuid.UUID) (*StatsResponse, error) {
 		response.ROI = (response.NetProfit / response.TotalWagered) * 100
 	}
 
+	// D. Closing Line Value (global y por deporte)
+	response.CLVBets = stats.CLVBets
+	response.AvgCLV = stats.AvgCLV
+	clvBySport, err := s.repo.GetCLVBySport(userID)
+	if err != nil {
+		return nil, err
+	}
+	response.CLVBySport = clvBySport
+
+	// E. Rendimiento por tipo de mercado
+	byMarket, err := s.repo.GetMarketBreakdown(userID)
+	if err != nil {
+		return nil, err
+	}
+	response.ByMarket = byMarket
+
 	return response, nil
 }
 
@@ -273,6 +332,8 @@ func (s *Service) GetUserDashboardStats(userID uuid.UUID, sportFilter string) (*
 	var totalBets int64 = int64(len(bets))
 	var wonBets int64 = 0
 	var totalProfit float64 = 0.0
+	var clvSum float64 = 0.0
+	var clvBets int64 = 0
 
 	// Mapa para agrupar rendimiento por deporte
 	sportMap := make(map[string]*SportStat)
@@ -287,17 +348,23 @@ func (s *Service) GetUserDashboardStats(userID uuid.UUID, sportFilter string) (*
 		currentSportStat.Bets++
 
 		// Calcular Ganancias/Pérdidas
-		// Asumimos que si está "won", ganamos (stake * odds) - stake
-		// Si está "lost", perdemos el stake.
+		// Ganada: (stake * odds) - stake. Perdida: -stake.
+		// Anulada/Push: 0. Cash-out: importe cobrado - stake.
 		// Si está "pending", no afecta el profit todavía.
-		if bet.Status == "WON" {
+		if bet.Status == StatusWon {
 			wonBets++
-			profit := (bet.StakeUnits * bet.Odds) - bet.StakeUnits
+		}
+		if profit, ok := settledProfit(bet); ok {
 			totalProfit += profit
 			currentSportStat.Profit += profit
-		} else if bet.Status == "LOST" {
-			totalProfit -= bet.StakeUnits
-			currentSportStat.Profit -= bet.StakeUnits
+		}
+
+		// Closing Line Value (solo apuestas con precio de cierre)
+		if bet.CLV != nil {
+			clvSum += *bet.CLV
+			clvBets++
+			currentSportStat.CLVBets++
+			currentSportStat.AvgCLV += *bet.CLV // Se divide al final
 		}
 	}
 
@@ -305,39 +372,57 @@ func (s *Service) GetUserDashboardStats(userID uuid.UUID, sportFilter string) (*
 	var winRate float64 = 0
 	// Solo contamos apuestas resueltas para el WinRate real (evitamos dividir por pendientes)
 	resolvedBets := 0
+	oddsSum := 0.0
 	for _, b := range bets {
-		if b.Status == "WON" || b.Status == "LOST" {
+		if b.Status == StatusWon || b.Status == StatusLost {
 			resolvedBets++
+			oddsSum += b.Odds
 		}
 	}
 
+	avgOdds := 0.0
 	if resolvedBets > 0 {
 		winRate = (float64(wonBets) / float64(resolvedBets)) * 100
+		avgOdds = oddsSum / float64(resolvedBets)
 	}
 
 	// 6. Obtener Bankroll actual del usuario (siempre el total real)
 	user, _ := s.repo.GetUserByID(userID)
 	currentBankroll := 0.0
+	staking := analytics.DefaultStakingSettings()
 	if user != nil {
 		currentBankroll = user.Bankroll
+		staking = user.Staking.ToAnalytics()
 	}
 
 	// Convertir el mapa de deportes a slice para la respuesta
 	var sportPerformance []SportStat
 	for _, stat := range sportMap {
+		if stat.CLVBets > 0 {
+			stat.AvgCLV /= float64(stat.CLVBets)
+		}
 		sportPerformance = append(sportPerformance, *stat)
 	}
 
+	avgCLV := 0.0
+	if clvBets > 0 {
+		avgCLV = clvSum / float64(clvBets)
+	}
+
 	// 7. Generar Tip Inteligente (Lógica Local)
 	input := analytics.StatsInput{
 		WinRate:     winRate,
 		TotalBets:   int(totalBets),
 		TotalProfit: totalProfit,
 		Bankroll:    currentBankroll,
+		AvgOdds:     avgOdds,
+		AvgCLV:      avgCLV,
+		CLVBets:     clvBets,
+		Staking:     staking,
 	}
 
-	advice := analytics.GenerateSmartTip(input)
-	aiTip := advice.Message
+	insights := analytics.GenerateInsights(input)
+	aiTip := analytics.SmartTipFrom(insights).Message
 
 	return &DashboardStatsResponse{
 		TotalBets:        totalBets,
@@ -345,7 +430,10 @@ func (s *Service) GetUserDashboardStats(userID uuid.UUID, sportFilter string) (*
 		WinRate:          winRate,
 		TotalProfit:      totalProfit,
 		CurrentBankroll:  currentBankroll,
+		AvgCLV:           avgCLV,
+		CLVBets:          clvBets,
 		AiTip:            aiTip,
+		Insights:         insights,
 		SportPerformance: sportPerformance,
 	}, nil
 }
@@ -353,22 +441,35 @@ func (s *Service) GetUserDashboardStats(userID uuid.UUID, sportFilter string) (*
 // Struct auxiliar para leer el JSON que guardamos en 'details'
 type BetDetails struct {
 	MatchID   string `json:"match_id"`
-	Selection string `json:"selection"` // "HOME" o "AWAY"
+	Selection string `json:"selection"` // "HOME", "AWAY" o "DRAW"
 	TeamName  string `json:"team_name"`
 }
 
-// SettleMatch resuelve todas las apuestas de un partido específico
-// winner: "HOME" o "AWAY"
-// SettleMatch resuelve todas las apuestas de un partido específico
-func (s *Service) SettleMatch(matchID uuid.UUID, winner string) error {
-	var bets []Bet
+// SettleMatch resuelve todas las apuestas de un partido específico.
+// result.Winner: "HOME", "AWAY", "DRAW" o "VOID" (partido cancelado); el marcador liquida hándicaps y totales.
+// Primero liquida las selecciones (legs) y consolida sus apuestas padre;
+// después resuelve las apuestas antiguas que solo guardan el partido en 'details'.
+// Si quedan selecciones sin poder resolverse (falta el marcador o los mapas) liquida lo
+// posible y devuelve ErrIncompleteResult: el resultado no debe darse por liquidado.
+func (s *Service) SettleMatch(result *market.MatchResult) error {
+	matchID := result.MatchID
+
+	// 1. Selecciones registradas (simples y combinadas)
+	resolvedCount, unresolved, err := s.repo.SettleMatchLegs(result)
+	if err != nil {
+		return err
+	}
 
-	// Traemos solo pendientes para optimizar
-	if err := s.repo.db.Where("status = ?", "pending").Find(&bets).Error; err != nil {
+	// 2. Apuestas antiguas sin selecciones
+	bets, err := s.repo.GetPendingBetsWithoutLegs()
+	if err != nil {
 		return err
 	}
+	threeWay := false
+	if match, err := s.repo.GetMatchByID(matchID); err == nil {
+		threeWay = match.ThreeWay()
+	}
 
-	resolvedCount := 0
 	for _, bet := range bets {
 		var details BetDetails
 		if err := json.Unmarshal([]byte(bet.Details), &details); err != nil {
@@ -380,14 +481,8 @@ func (s *Service) SettleMatch(matchID uuid.UUID, winner string) error {
 			continue
 		}
 
-		// Determinar resultado
-		newStatus := "LOST"
-		if details.Selection == winner {
-			newStatus = "WON"
-		}
-
 		// Resolver atómicamente
-		if err := s.repo.ResolveBet(bet.ID.String(), newStatus); err == nil {
+		if err := s.repo.ResolveBet(bet.ID.String(), legOutcome(details.Selection, result.Winner, threeWay), 0); err == nil {
 			resolvedCount++
 		}
 	}
@@ -396,6 +491,9 @@ func (s *Service) SettleMatch(matchID uuid.UUID, winner string) error {
 		log.Printf("✅ %d apuestas resueltas para el partido %s", resolvedCount, matchID)
 	}
 
+	if unresolved > 0 {
+		return fmt.Errorf("%w: %d selecciones del partido %s necesitan marcador o resultado por mapa", ErrIncompleteResult, unresolved, matchID)
+	}
 	return nil
 }
 
@@ -0,0 +1,155 @@
+package betting
+
+import "github.com/xnzperez/sports-analytics-backend/internal/market"
+
+// legOutcome traduce el ganador de un partido al estado de una selección.
+// winner: "HOME", "AWAY", "DRAW" o "VOID" (partido cancelado).
+// En un mercado a dos vías (threeWay=false) el empate anula las apuestas a HOME/AWAY;
+// a tres vías las pierde.
+func legOutcome(selection string, winner string, threeWay bool) string {
+	if winner == StatusVoid {
+		return StatusVoid
+	}
+	if selection == winner {
+		return StatusWon
+	}
+	if winner == "DRAW" && !threeWay {
+		return StatusVoid
+	}
+	return StatusLost
+}
+
+// resultHasScore indica si el resultado trae marcador (necesario para hándicaps y totales).
+// En esports el marcador es de mapas, así que un 0-0 con ganador significa "sin marcador".
+func resultHasScore(result *market.MatchResult) bool {
+	return result.HomeScore > 0 || result.AwayScore > 0
+}
+
+// legResult calcula el estado de una selección con el resultado de su partido.
+// threeWay indica si el ganador del partido se ofrecía con empate (ver market.Match.ThreeWay).
+// ok=false si el resultado no basta para liquidarla (hándicap sin marcador, mapa sin resultado).
+func legResult(leg BetLeg, result *market.MatchResult, threeWay bool) (status string, ok bool) {
+	if result.Winner == StatusVoid {
+		return StatusVoid, true
+	}
+	if leg.Period > 0 {
+		return periodLegResult(leg, result)
+	}
+
+	switch leg.MarketType {
+	case market.MarketTypeSpread, market.MarketTypeTotal:
+		if !resultHasScore(result) {
+			return "", false
+		}
+		return lineOutcome(leg.MarketType, leg.Selection, leg.Line, result.HomeScore, result.AwayScore), true
+	}
+	return legOutcome(leg.Selection, result.Winner, threeWay), true
+}
+
+// periodLegResult liquida una selección de un mapa con el resultado de ese mapa.
+// Si la fuente informó los mapas y este no aparece, el mapa no se jugó (p. ej. un Bo3
+// que terminó 2-0) y la selección se anula.
+func periodLegResult(leg BetLeg, result *market.MatchResult) (status string, ok bool) {
+	period := result.PeriodFor(leg.Period)
+	if period == nil {
+		if len(result.Periods) == 0 {
+			return "", false
+		}
+		return StatusVoid, true
+	}
+	if period.Winner == StatusVoid {
+		return StatusVoid, true
+	}
+
+	switch leg.MarketType {
+	case market.MarketTypeSpread, market.MarketTypeTotal:
+		return lineOutcome(leg.MarketType, leg.Selection, leg.Line, period.HomeScore, period.AwayScore), true
+	}
+	// Los ganadores de mapa se ofrecen siempre a dos vías
+	return legOutcome(leg.Selection, period.Winner, false), true
+}
+
+// rollupLegs calcula el estado de la apuesta padre a partir de sus selecciones.
+// factor es lo que se paga por unidad de stake abierto (ver payoutFactor).
+//
+// Reglas de la combinada:
+//   - Si cualquier selección pierde, la apuesta pierde (aunque queden otras pendientes).
+//   - Las selecciones anuladas o con PUSH cuentan como cuota 1.00 (la combinada se recalcula).
+//   - Las medio ganadas/perdidas multiplican por su factor ((cuota+1)/2 o 0.5).
+//   - Solo paga cuando todas las selecciones restantes tienen resultado.
+//
+// Devuelve settled=false mientras el resultado no sea definitivo.
+func rollupLegs(legs []BetLeg) (status string, factor float64, settled bool) {
+	factor = 1.0
+	pending := false
+	resolved := 0 // Selecciones ganadas o liquidadas a medias
+	pushed := false
+
+	for _, leg := range legs {
+		switch leg.Status {
+		case StatusLost:
+			return StatusLost, 0, true
+		case StatusWon, StatusHalfWon, StatusHalfLost:
+			factor *= payoutFactor(leg.Status, leg.Odds)
+			resolved++
+		case StatusVoid:
+			// Selección anulada: no multiplica la cuota
+		case StatusPush:
+			pushed = true
+		default:
+			pending = true
+		}
+	}
+
+	if pending {
+		return StatusPending, 0, false
+	}
+
+	// Todas las selecciones anuladas o empatadas contra la línea: se devuelve el stake
+	if resolved == 0 {
+		if pushed {
+			return StatusPush, 1.0, true
+		}
+		return StatusVoid, 1.0, true
+	}
+
+	// Apuesta simple (o una sola selección con resultado): hereda el estado de la selección
+	if resolved == 1 {
+		for _, leg := range legs {
+			if leg.Status == StatusWon || leg.Status == StatusHalfWon || leg.Status == StatusHalfLost {
+				return leg.Status, factor, true
+			}
+		}
+	}
+
+	// Combinada con medias liquidaciones: el estado refleja si se cobra más o menos que el stake
+	switch {
+	case factor > 1:
+		return StatusWon, factor, true
+	case factor == 1:
+		return StatusPush, factor, true
+	}
+	return StatusHalfLost, factor, true
+}
+
+// payoutFor calcula cuánto se devuelve al usuario según el resultado.
+// El importe de un CASHOUT no depende de la cuota y lo fija quien liquida.
+// Solo se liquida el stake abierto: lo cerrado con cash-out parcial ya se pagó.
+func payoutFor(bet *Bet, outcome string) float64 {
+	return settlementPayout(outcome, bet.OpenStake(), bet.Odds)
+}
+
+// settledProfit devuelve la ganancia neta de una apuesta resuelta.
+// ok=false si la apuesta sigue pendiente.
+func settledProfit(bet Bet) (profit float64, ok bool) {
+	switch bet.Status {
+	case StatusPending:
+		return 0, false
+	case StatusWon:
+		// Apuestas anteriores a la columna payout: se reconstruye desde la cuota
+		if bet.Payout == 0 {
+			return bet.StakeUnits*bet.Odds - bet.StakeUnits, true
+		}
+	}
+	return bet.Payout - bet.StakeUnits, true
+}
@@ -0,0 +1,79 @@
+package betting
+
+import (
+	"github.com/google/uuid"
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
+)
+
+// simulationBaseBankroll es el bankroll de referencia si el usuario no tiene saldo
+const simulationBaseBankroll = 100.0
+
+// SimulateBankroll proyecta el bankroll del usuario remuestreando sus apuestas resueltas
+// (Monte Carlo). El stake de cada apuesta se expresa como % del saldo que tenía al apostar,
+// reconstruido desde el extracto, para que la simulación respete su forma de gestionar la banca.
+func (s *Service) SimulateBankroll(userID uuid.UUID, params analytics.SimulationParams) (*analytics.SimulationResult, error) {
+	// 1. Saldo actual y extracto
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := s.repo.GetLedger(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Saldo justo antes de cada apuesta (saldo inicial = actual - suma del extracto)
+	balance := user.Bankroll
+	for _, tx := range transactions {
+		balance -= tx.Amount
+	}
+	balanceBefore := make(map[uuid.UUID]float64)
+	for _, tx := range transactions {
+		if tx.Type == "BET_PLACED" && tx.ReferenceID != nil {
+			balanceBefore[*tx.ReferenceID] = balance
+		}
+		balance += tx.Amount
+	}
+
+	// 3. Muestras: solo apuestas ganadas o perdidas
+	bets, err := s.repo.GetResolvedBets(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]analytics.SimulationSample, 0, len(bets))
+	for _, bet := range bets {
+		if bet.Status != StatusWon && bet.Status != StatusLost {
+			continue
+		}
+		before, ok := balanceBefore[bet.ID]
+		if !ok || before <= 0 {
+			before = user.Bankroll
+		}
+		if before <= 0 {
+			continue
+		}
+		stakePct := bet.StakeUnits / before
+		if stakePct > 1 {
+			stakePct = 1
+		}
+		samples = append(samples, analytics.SimulationSample{
+			Odds:     bet.Odds,
+			StakePct: stakePct,
+			Won:      bet.Status == StatusWon,
+		})
+	}
+
+	// 4. Simular desde el bankroll actual
+	params.Bankroll = user.Bankroll
+	if params.Bankroll <= 0 {
+		params.Bankroll = simulationBaseBankroll
+	}
+
+	result, err := analytics.SimulateBankroll(samples, params)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
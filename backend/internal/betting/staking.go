@@ -0,0 +1,26 @@
+package betting
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
+)
+
+// SuggestStake calcula el stake recomendado para una cuota y la probabilidad estimada
+// por el usuario, según su bankroll actual y su configuración de staking.
+// Los métodos Kelly exigen la probabilidad: sin ella el stake sería siempre 0.
+func (s *Service) SuggestStake(userID uuid.UUID, odds, probability float64) (*analytics.StakeSuggestion, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := user.Staking.ToAnalytics()
+	if settings.UsesKelly() && (probability <= 0 || probability >= 1) {
+		return nil, fmt.Errorf("%w: el método %s necesita la probabilidad (probability) entre 0 y 1", ErrInvalidBet, settings.Method)
+	}
+
+	suggestion := analytics.SuggestStake(user.Bankroll, odds, probability, settings)
+	return &suggestion, nil
+}
@@ -0,0 +1,214 @@
+package betting
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xnzperez/sports-analytics-backend/internal/market"
+)
+
+// defaultSlippageTolerance es la diferencia relativa máxima aceptada entre la cuota
+// enviada por el cliente y la del mercado (0.02 = 2%) si no se configura ODDS_SLIPPAGE_TOLERANCE.
+const defaultSlippageTolerance = 0.02
+
+// OddsChangedError se devuelve cuando la cuota del cliente ya no coincide con el mercado.
+// Incluye el precio actual para que el frontend pueda ofrecerlo al usuario.
+type OddsChangedError struct {
+	MatchID       uuid.UUID  `json:"match_id"`
+	MarketLineID  *uuid.UUID `json:"market_line_id,omitempty"`
+	Selection     string     `json:"selection"`
+	RequestedOdds float64    `json:"requested_odds"`
+	CurrentOdds   float64    `json:"current_odds"`
+}
+
+func (e *OddsChangedError) Error() string {
+	return fmt.Sprintf("la cuota ha cambiado: solicitada %.2f, actual %.2f", e.RequestedOdds, e.CurrentOdds)
+}
+
+// slippageTolerance lee la tolerancia configurada en el entorno
+func slippageTolerance() float64 {
+	if raw := os.Getenv("ODDS_SLIPPAGE_TOLERANCE"); raw != "" {
+		if tolerance, err := strconv.ParseFloat(raw, 64); err == nil && tolerance >= 0 {
+			return tolerance
+		}
+	}
+	return defaultSlippageTolerance
+}
+
+// marketRef identifica el origen de los datos de mercado de una apuesta
+type marketRef struct {
+	ExternalID string // Solo en apuestas simples
+	Provider   string // "mixed" si una combinada mezcla proveedores
+}
+
+// buildLegs valida la petición contra el mercado sincronizado y la convierte en selecciones.
+// Devuelve también el partido/proveedor de origen para indexar la apuesta.
+//
+// Para combinadas la cuota total se calcula en el servidor como el producto de las selecciones;
+// el valor total enviado por el cliente se ignora.
+func (s *Service) buildLegs(req *PlaceBetRequest) ([]BetLeg, marketRef, error) {
+	// La probabilidad estimada por el usuario es opcional, pero debe ser una probabilidad
+	if p := req.EstimatedProbability; p != nil && (*p <= 0 || *p >= 1) {
+		return nil, marketRef{}, fmt.Errorf("%w: estimated_probability debe estar entre 0 y 1", ErrInvalidBet)
+	}
+
+	// A. Apuesta simple: el partido viene en details (formato original del frontend)
+	if len(req.Legs) == 0 {
+		legReq := LegRequest{Odds: req.Odds}
+		if req.Details != nil {
+			legReq.MatchID, _ = req.Details["match_id"].(string)
+			legReq.MarketLineID, _ = req.Details["market_line_id"].(string)
+			legReq.Selection, _ = req.Details["selection"].(string)
+		}
+		if legReq.MatchID == "" {
+			return nil, marketRef{}, fmt.Errorf("%w: la apuesta debe referenciar un partido del mercado", ErrInvalidBet)
+		}
+
+		leg, match, err := s.priceLeg(legReq)
+		if err != nil {
+			return nil, marketRef{}, err
+		}
+
+		req.Odds = leg.Odds
+		req.SportKey = match.SportKey
+		if req.Title == "" {
+			req.Title = match.HomeTeam + " vs " + match.AwayTeam
+		}
+		return []BetLeg{leg}, marketRef{ExternalID: match.ExternalID, Provider: match.Provider}, nil
+	}
+
+	// B. Combinada: cada selección se valida por separado
+	legs := make([]BetLeg, 0, len(req.Legs))
+	seen := make(map[string]bool)
+	combinedOdds := 1.0
+	sportKey := ""
+	provider := ""
+
+	for _, legReq := range req.Legs {
+		if seen[legReq.MatchID] {
+			return nil, marketRef{}, fmt.Errorf("%w: no se puede repetir un partido en la misma combinada", ErrInvalidBet)
+		}
+		seen[legReq.MatchID] = true
+
+		leg, match, err := s.priceLeg(legReq)
+		if err != nil {
+			return nil, marketRef{}, err
+		}
+		combinedOdds *= leg.Odds
+
+		// Deporte común de la combinada (o "mixed" si mezcla varios)
+		if sportKey == "" {
+			sportKey = match.SportKey
+		} else if sportKey != match.SportKey {
+			sportKey = "mixed"
+		}
+		if provider == "" {
+			provider = match.Provider
+		} else if provider != match.Provider {
+			provider = "mixed"
+		}
+
+		legs = append(legs, leg)
+	}
+
+	req.Odds = math.Round(combinedOdds*100) / 100
+	req.SportKey = sportKey
+	if req.Title == "" {
+		req.Title = fmt.Sprintf("Combinada de %d selecciones", len(legs))
+	}
+
+	return legs, marketRef{Provider: provider}, nil
+}
+
+// priceLeg busca el partido (y la línea, si la hay) referenciado, comprueba que admite
+// apuestas y fija la cuota. legReq.Odds es el precio que vio el usuario: se acepta si está
+// dentro de la tolerancia; si es 0 se usa directamente la cuota del mercado.
+func (s *Service) priceLeg(legReq LegRequest) (BetLeg, *market.Match, error) {
+	matchID, err := uuid.Parse(legReq.MatchID)
+	if err != nil {
+		return BetLeg{}, nil, fmt.Errorf("%w: ID de partido inválido", ErrInvalidBet)
+	}
+
+	// 1. El partido debe existir en nuestro mercado
+	match, err := s.repo.GetMatchByID(matchID)
+	if err != nil {
+		return BetLeg{}, nil, fmt.Errorf("%w: partido %s no encontrado", ErrInvalidBet, legReq.MatchID)
+	}
+
+	// 2. Y seguir abierto a apuestas
+	if match.Status == "finished" {
+		return BetLeg{}, nil, fmt.Errorf("%w: el partido ya ha finalizado", ErrInvalidBet)
+	}
+	if match.Status == "removed" {
+		return BetLeg{}, nil, fmt.Errorf("%w: el mercado ya no está disponible", ErrInvalidBet)
+	}
+	if !match.StartsAt.IsZero() && !time.Now().Before(match.StartsAt) {
+		return BetLeg{}, nil, fmt.Errorf("%w: el partido ya ha comenzado", ErrInvalidBet)
+	}
+
+	leg := BetLeg{
+		MatchID:    match.ID,
+		MarketType: market.MarketTypeMoneyline,
+		Selection:  legReq.Selection,
+		TeamName:   match.TeamFor(legReq.Selection),
+		Status:     StatusPending,
+	}
+	currentOdds := match.OddsFor(legReq.Selection)
+
+	// 3. Hándicap o total: el precio sale de la línea concreta
+	if legReq.MarketLineID != "" {
+		line, err := s.marketLine(match, legReq.MarketLineID)
+		if err != nil {
+			return BetLeg{}, nil, err
+		}
+		leg.MarketLineID = &line.ID
+		leg.MarketType = line.Type
+		leg.Period = line.Period
+		leg.Line = line.Line
+		leg.TeamName = line.Label(match, legReq.Selection)
+		currentOdds = line.OddsFor(legReq.Selection)
+	}
+
+	// 4. La selección debe tener precio
+	if currentOdds <= 1 {
+		return BetLeg{}, nil, fmt.Errorf("%w: selección '%s' no disponible", ErrInvalidBet, legReq.Selection)
+	}
+
+	// 5. Comparar el precio del cliente con el del mercado.
+	// La tolerancia solo decide si se acepta: siempre se guarda el precio del servidor.
+	leg.Odds = currentOdds
+	if legReq.Odds > 0 {
+		if math.Abs(legReq.Odds-currentOdds)/currentOdds > slippageTolerance() {
+			return BetLeg{}, nil, &OddsChangedError{
+				MatchID:       match.ID,
+				MarketLineID:  leg.MarketLineID,
+				Selection:     legReq.Selection,
+				RequestedOdds: legReq.Odds,
+				CurrentOdds:   currentOdds,
+			}
+		}
+	}
+
+	return leg, match, nil
+}
+
+// marketLine busca una línea abierta del partido
+func (s *Service) marketLine(match *market.Match, lineIDStr string) (*market.MarketLine, error) {
+	lineID, err := uuid.Parse(lineIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ID de línea inválido", ErrInvalidBet)
+	}
+
+	line, err := s.repo.GetMarketLine(lineID)
+	if err != nil || line.MatchID != match.ID {
+		return nil, fmt.Errorf("%w: línea %s no encontrada en el partido", ErrInvalidBet, lineIDStr)
+	}
+	if line.Status != "open" {
+		return nil, fmt.Errorf("%w: la línea ya no está disponible", ErrInvalidBet)
+	}
+	return line, nil
+}
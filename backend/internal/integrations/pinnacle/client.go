@@ -26,6 +26,16 @@ func NewClient() *Client {
 	}
 }
 
+// APIError es un error HTTP devuelto por RapidAPI/Pinnacle
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API Error: Status %d", e.StatusCode)
+}
+
 func (c *Client) makeRequest(method, endpoint string) ([]byte, error) {
 	url := fmt.Sprintf("%s%s", c.baseURL, endpoint)
 
@@ -48,7 +58,7 @@ func (c *Client) makeRequest(method, endpoint string) ([]byte, error) {
 	if res.StatusCode != 200 {
 		// Imprimimos el error para debuggear
 		fmt.Printf("⚠️  RAPIDAPI ERROR: %s\n", string(body))
-		return nil, fmt.Errorf("API Error: Status %d", res.StatusCode)
+		return nil, &APIError{StatusCode: res.StatusCode, Body: string(body)}
 	}
 
 	return body, nil
@@ -62,13 +72,43 @@ type MoneyLine struct {
 	Draw float64 `json:"draw,omitempty"` // En Esports a veces no hay empate, por eso omitempty
 }
 
+// Spread es una línea de hándicap. Hdp es el hándicap del local (el visitante tiene -Hdp).
+type Spread struct {
+	Hdp  float64 `json:"hdp"`
+	Home float64 `json:"home"`
+	Away float64 `json:"away"`
+}
+
+// Total es una línea de over/under sobre la suma del marcador (en esports: mapas)
+type Total struct {
+	Points float64 `json:"points"`
+	Over   float64 `json:"over"`
+	Under  float64 `json:"under"`
+}
+
+// Period0 es la estructura de cualquier periodo (num_0 = partido completo, num_N = mapa N)
 type Period0 struct {
 	MoneyLine MoneyLine `json:"money_line"`
-	Cutoff    string    `json:"cutoff"` // Fecha límite para apostar
+	// Spreads y Totals vienen indexados por la línea como texto ("-1.5", "2.5")
+	Spreads map[string]Spread `json:"spreads,omitempty"`
+	Totals  map[string]Total  `json:"totals,omitempty"`
+	Cutoff  string            `json:"cutoff"` // Fecha límite para apostar
 }
 
 type Periods struct {
 	Num0 Period0 `json:"num_0"` // num_0 = Match Winner (Partido completo)
+
+	// En esports cada periodo adicional es un mapa (hasta un Bo5)
+	Num1 Period0 `json:"num_1"`
+	Num2 Period0 `json:"num_2"`
+	Num3 Period0 `json:"num_3"`
+	Num4 Period0 `json:"num_4"`
+	Num5 Period0 `json:"num_5"`
+}
+
+// Maps devuelve los periodos por mapa indexados por su número (1..5)
+func (p Periods) Maps() map[int]Period0 {
+	return map[int]Period0{1: p.Num1, 2: p.Num2, 3: p.Num3, 4: p.Num4, 5: p.Num5}
 }
 
 type Event struct {
@@ -78,6 +118,9 @@ type Event struct {
 	Home       string  `json:"home"`
 	Away       string  `json:"away"`
 	Periods    Periods `json:"periods"`
+
+	// IsHaveOdds llega en false dentro de un delta cuando el evento deja de ofrecerse
+	IsHaveOdds *bool `json:"is_have_odds,omitempty"`
 }
 
 type MarketsResponse struct {
@@ -90,8 +133,18 @@ type MarketsResponse struct {
 // GetEsportsMarkets trae los partidos de Esports (ID 12)
 // Documentación: /kit/v1/markets
 func (c *Client) GetEsportsMarkets() (*MarketsResponse, error) {
+	return c.GetEsportsMarketsSince(0)
+}
+
+// GetEsportsMarketsSince trae solo los cambios desde el cursor 'since' (MarketsResponse.Last
+// de la llamada anterior). Con since=0 devuelve la lista completa con cuotas.
+func (c *Client) GetEsportsMarketsSince(since int64) (*MarketsResponse, error) {
 	// CAMBIO: sport_id=10 (Esports según tu descubrimiento)
 	endpoint := "/kit/v1/markets?sport_id=10&is_have_odds=true"
+	if since > 0 {
+		// En un delta necesitamos también los eventos retirados (sin cuotas)
+		endpoint = fmt.Sprintf("/kit/v1/markets?sport_id=10&since=%d", since)
+	}
 
 	fmt.Println("DEBUG: Consultando Esports ->", endpoint)
 
@@ -108,6 +161,73 @@ func (c *Client) GetEsportsMarkets() (*MarketsResponse, error) {
 	return &response, nil
 }
 
+// GetEventDetails trae las cuotas actuales de un evento concreto
+// Documentación: /kit/v1/details
+func (c *Client) GetEventDetails(eventID string) (*MarketsResponse, error) {
+	body, err := c.makeRequest("GET", "/kit/v1/details?event_id="+eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	var response MarketsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// --- RESULTADOS (ARCHIVO DE EVENTOS LIQUIDADOS) ---
+
+// Estados de liquidación de un periodo según la documentación de Pinnacle
+const (
+	SettlementSettled          = 1
+	SettlementResettled        = 2
+	SettlementCancelled        = 3
+	SettlementResettleCanceled = 4
+	SettlementDeleted          = 5
+)
+
+type PeriodResult struct {
+	Number     int    `json:"number"` // 0 = partido completo
+	Status     int    `json:"status"` // Ver constantes Settlement*
+	SettledAt  string `json:"settled_at"`
+	Team1Score int    `json:"team_1_score"` // Local
+	Team2Score int    `json:"team_2_score"` // Visitante
+}
+
+type ArchiveEvent struct {
+	EventID       int64          `json:"event_id"`
+	LeagueName    string         `json:"league_name"`
+	Starts        string         `json:"starts"`
+	Home          string         `json:"home"`
+	Away          string         `json:"away"`
+	PeriodResults []PeriodResult `json:"period_results"`
+}
+
+type ArchiveResponse struct {
+	SportID int            `json:"sport_id"`
+	Events  []ArchiveEvent `json:"events"`
+}
+
+// GetEsportsResults trae los eventos de Esports ya liquidados con su marcador
+// Documentación: /kit/v1/archive
+func (c *Client) GetEsportsResults() (*ArchiveResponse, error) {
+	endpoint := "/kit/v1/archive?sport_id=10&page_num=1"
+
+	body, err := c.makeRequest("GET", endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var response ArchiveResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
 // GetSports obtiene la lista de todos los deportes disponibles y sus IDs
 func (c *Client) GetSports() ([]byte, error) {
 	// Endpoint estándar según tu documentación: @List of sports
@@ -1,6 +1,7 @@
 package market
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -25,14 +26,330 @@ type Match struct {
 	AwayTeam string    `json:"away_team"` // "JD Gaming"
 	StartsAt time.Time `json:"starts_at"` // Cuándo juega
 
-	// Cuotas (Odds) - Solo guardamos Ganador (MoneyLine) por ahora
+	// Cuotas (Odds) del ganador (MoneyLine)
+	// HomeOdds/AwayOdds son siempre el último precio observado.
+	// DrawOdds > 0 indica un mercado a tres vías (fútbol, Bo2 en esports).
 	HomeOdds float64 `json:"home_odds"`
 	AwayOdds float64 `json:"away_odds"`
+	DrawOdds float64 `json:"draw_odds,omitempty"`
+
+	// Precio de apertura (primera cuota que vimos del partido)
+	OpeningHomeOdds float64 `json:"opening_home_odds"`
+	OpeningAwayOdds float64 `json:"opening_away_odds"`
+	OpeningDrawOdds float64 `json:"opening_draw_odds,omitempty"`
+
+	// Precio de cierre (última cuota al comenzar el partido), base del CLV
+	ClosingHomeOdds   float64    `json:"closing_home_odds"`
+	ClosingAwayOdds   float64    `json:"closing_away_odds"`
+	ClosingDrawOdds   float64    `json:"closing_draw_odds,omitempty"`
+	ClosingCapturedAt *time.Time `json:"closing_captured_at,omitempty"`
 
 	// Estado
-	Status string `gorm:"default:'scheduled'" json:"status"` // scheduled, live, finished
+	Status string `gorm:"default:'scheduled'" json:"status"` // scheduled, live, finished, removed
+
+	// Resultado oficial (nil mientras el partido no haya terminado)
+	Result *MatchResult `gorm:"foreignKey:MatchID" json:"result,omitempty"`
+
+	// Líneas adicionales del partido (hándicaps y totales)
+	Lines []MarketLine `gorm:"foreignKey:MatchID" json:"lines,omitempty"`
+
+	// Margen y cuotas justas del ganador; se calcula al listar (no se guarda)
+	Pricing *MarketPricing `gorm:"-" json:"pricing,omitempty"`
+
+	// Probabilidad del modelo Elo para comparar con la casa; se calcula al listar (no se guarda)
+	Model *ModelPrice `gorm:"-" json:"model,omitempty"`
 }
 
 func (Match) TableName() string {
 	return "matches" // <-- ASEGÚRATE de que este sea el nombre exacto en tu pgAdmin
 }
+
+// Tipos de mercado de un partido
+const (
+	MarketTypeMoneyline = "moneyline" // Ganador: del partido en el Match, de cada mapa en una MarketLine
+	MarketTypeSpread    = "spread"    // Hándicap: selecciones HOME / AWAY
+	MarketTypeTotal     = "total"     // Over/Under: selecciones OVER / UNDER
+)
+
+// MarketLine es un mercado hijo de un partido: hándicaps y totales del partido completo
+// (Period 0) y los mercados de cada mapa (Period N: ganador, hándicap y total del mapa N).
+// Un partido puede tener varias líneas del mismo tipo (-1.5, +1.5...);
+// cada combinación partido/periodo/tipo/línea es única.
+type MarketLine struct {
+	ID      uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MatchID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_market_lines_key" json:"match_id"`
+	Period  int       `gorm:"not null;default:0;uniqueIndex:idx_market_lines_key" json:"period"` // 0 = partido completo
+	Type    string    `gorm:"not null;uniqueIndex:idx_market_lines_key" json:"type"`             // moneyline, spread, total
+	Line    float64   `gorm:"not null;uniqueIndex:idx_market_lines_key" json:"line"`             // Hándicap del local o total de puntos (0 en moneyline)
+
+	// Moneyline y spread: HomeOdds/AwayOdds. Total: HomeOdds = Over, AwayOdds = Under
+	HomeOdds float64 `json:"home_odds"`
+	AwayOdds float64 `json:"away_odds"`
+
+	// Precio al comenzar el partido (base del CLV)
+	ClosingHomeOdds float64 `json:"closing_home_odds"`
+	ClosingAwayOdds float64 `json:"closing_away_odds"`
+
+	Status    string    `gorm:"default:'open'" json:"status"` // open, removed
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Margen y cuotas justas de la línea; se calcula al listar (no se guarda)
+	Pricing *MarketPricing `gorm:"-" json:"pricing,omitempty"`
+}
+
+func (MarketLine) TableName() string {
+	return "market_lines"
+}
+
+// OddsFor devuelve la cuota actual de una selección de la línea (0 si no existe)
+func (l *MarketLine) OddsFor(selection string) float64 {
+	switch {
+	case l.Type != MarketTypeTotal && selection == "HOME", l.Type == MarketTypeTotal && selection == "OVER":
+		return l.HomeOdds
+	case l.Type != MarketTypeTotal && selection == "AWAY", l.Type == MarketTypeTotal && selection == "UNDER":
+		return l.AwayOdds
+	}
+	return 0
+}
+
+// ClosingOddsFor devuelve la cuota de cierre de una selección de la línea
+func (l *MarketLine) ClosingOddsFor(selection string) float64 {
+	switch {
+	case l.Type != MarketTypeTotal && selection == "HOME", l.Type == MarketTypeTotal && selection == "OVER":
+		return l.ClosingHomeOdds
+	case l.Type != MarketTypeTotal && selection == "AWAY", l.Type == MarketTypeTotal && selection == "UNDER":
+		return l.ClosingAwayOdds
+	}
+	return 0
+}
+
+// Label describe la selección para mostrarla en el ticket ("T1 -1.5", "Over 2.5", "T1 (Mapa 1)")
+func (l *MarketLine) Label(match *Match, selection string) string {
+	label := ""
+	switch {
+	case l.Type == MarketTypeMoneyline:
+		label = match.TeamFor(selection)
+	case selection == "HOME":
+		label = fmt.Sprintf("%s %+g", match.HomeTeam, l.Line)
+	case selection == "AWAY":
+		label = fmt.Sprintf("%s %+g", match.AwayTeam, -l.Line)
+	case selection == "OVER":
+		label = fmt.Sprintf("Over %g", l.Line)
+	case selection == "UNDER":
+		label = fmt.Sprintf("Under %g", l.Line)
+	}
+	if label != "" && l.Period > 0 {
+		label += fmt.Sprintf(" (Mapa %d)", l.Period)
+	}
+	return label
+}
+
+// OddsSnapshot guarda cada cambio de precio observado en un partido,
+// para poder graficar el movimiento de la línea.
+type OddsSnapshot struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MatchID    uuid.UUID `gorm:"type:uuid;not null;index:idx_odds_snapshots_match_time" json:"match_id"`
+	HomeOdds   float64   `json:"home_odds"`
+	AwayOdds   float64   `json:"away_odds"`
+	DrawOdds   float64   `json:"draw_odds,omitempty"`
+	CapturedAt time.Time `gorm:"not null;index:idx_odds_snapshots_match_time" json:"captured_at"`
+}
+
+func (OddsSnapshot) TableName() string {
+	return "odds_snapshots"
+}
+
+// SyncCursor guarda el último cursor 'since' recibido por proveedor y deporte,
+// para que la siguiente sincronización pida solo los cambios.
+type SyncCursor struct {
+	Provider  string    `gorm:"primaryKey" json:"provider"`
+	SportID   int       `gorm:"primaryKey;autoIncrement:false" json:"sport_id"`
+	Last      int64     `json:"last"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (SyncCursor) TableName() string {
+	return "sync_cursors"
+}
+
+// Tipos de sincronización y quién la disparó
+const (
+	SyncModeFull  = "full"
+	SyncModeDelta = "delta"
+
+	SyncTriggerScheduler = "scheduler"
+	SyncTriggerManual    = "manual"
+)
+
+// SyncRun registra cada ejecución de la sincronización de mercados,
+// para saber cuándo se actualizaron los datos por última vez y por qué falló.
+type SyncRun struct {
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Provider string    `json:"provider"`
+	Mode     string    `json:"mode"`    // full, delta
+	Trigger  string    `json:"trigger"` // scheduler, manual
+
+	StartedAt  time.Time  `gorm:"index" json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	EventsSeen int `json:"events_seen"`
+	Inserted   int `json:"inserted"`
+	Updated    int `json:"updated"`
+	Skipped    int `json:"skipped"` // Sin cuotas válidas o sin cambios
+	Removed    int `json:"removed"`
+
+	Error string `json:"error,omitempty"`
+}
+
+func (SyncRun) TableName() string {
+	return "sync_runs"
+}
+
+// Orígenes posibles de un resultado
+const (
+	ResultSourceProvider   = "provider"
+	ResultSourceManual     = "manual"
+	ResultSourceSimulation = "simulation" // Solo en DEMO_MODE
+)
+
+// MatchResult guarda el resultado final de un partido.
+// El worker solo liquida apuestas de partidos con un resultado confirmado.
+type MatchResult struct {
+	ID      uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MatchID uuid.UUID `gorm:"type:uuid;uniqueIndex;not null" json:"match_id"`
+
+	Winner     string    `gorm:"not null" json:"winner"` // "HOME", "AWAY", "DRAW" o "VOID" (cancelado)
+	HomeScore  int       `json:"home_score"`
+	AwayScore  int       `json:"away_score"`
+	FinishedAt time.Time `json:"finished_at"`
+
+	Source    string     `json:"source"`                         // provider, manual, simulation
+	Confirmed bool       `gorm:"default:false" json:"confirmed"` // Solo los confirmados se liquidan
+	SettledAt *time.Time `json:"settled_at,omitempty"`           // Cuándo se liquidaron las apuestas
+
+	// Resultado de cada mapa (puede venir vacío si la fuente solo da el marcador final)
+	Periods []PeriodResult `gorm:"foreignKey:MatchID;references:MatchID" json:"periods,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (MatchResult) TableName() string {
+	return "match_results"
+}
+
+// PeriodResult guarda el resultado de un mapa (periodo) de un partido.
+// Los mercados de mapa (MarketLine con Period > 0) se liquidan con él.
+type PeriodResult struct {
+	ID      uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MatchID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_period_results_key" json:"match_id"`
+	Period  int       `gorm:"not null;uniqueIndex:idx_period_results_key" json:"period"` // 1 = primer mapa
+
+	Winner    string `json:"winner"`     // "HOME", "AWAY", "DRAW" o "VOID" (mapa cancelado)
+	HomeScore int    `json:"home_score"` // Rondas, kills... según el juego
+	AwayScore int    `json:"away_score"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (PeriodResult) TableName() string {
+	return "period_results"
+}
+
+// PeriodFor devuelve el resultado de un mapa (nil si no consta)
+func (r *MatchResult) PeriodFor(period int) *PeriodResult {
+	for i := range r.Periods {
+		if r.Periods[i].Period == period {
+			return &r.Periods[i]
+		}
+	}
+	return nil
+}
+
+// TeamRating es la fuerza actual (Elo) de un equipo en un juego.
+// Se actualiza cada vez que se liquida un resultado suyo.
+type TeamRating struct {
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SportKey string    `gorm:"not null;uniqueIndex:idx_team_ratings_key" json:"sport_key"`
+	Team     string    `gorm:"not null;uniqueIndex:idx_team_ratings_key" json:"team"`
+
+	Rating  float64 `gorm:"not null" json:"rating"`
+	Matches int     `gorm:"default:0" json:"matches"` // Partidos con los que se ha calculado
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (TeamRating) TableName() string {
+	return "team_ratings"
+}
+
+// RatingHistory guarda cómo cambió el rating de un equipo en cada partido.
+// Un partido solo puntúa una vez por equipo (índice único), así el cálculo es idempotente.
+type RatingHistory struct {
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SportKey string    `gorm:"not null;index:idx_rating_history_team" json:"sport_key"`
+	Team     string    `gorm:"not null;index:idx_rating_history_team;uniqueIndex:idx_rating_history_match" json:"team"`
+	MatchID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_rating_history_match" json:"match_id"`
+	Opponent string    `json:"opponent"`
+
+	RatingBefore float64   `json:"rating_before"`
+	RatingAfter  float64   `json:"rating_after"`
+	Expected     float64   `json:"expected"` // Probabilidad de victoria que daba el modelo antes del partido
+	Score        float64   `json:"score"`    // 1 victoria, 0.5 empate, 0 derrota
+	PlayedAt     time.Time `json:"played_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (RatingHistory) TableName() string {
+	return "rating_history"
+}
+
+// OddsFor devuelve la cuota actual de una selección ("HOME", "AWAY" o "DRAW").
+// Devuelve 0 si la selección no existe en este mercado.
+func (m *Match) OddsFor(selection string) float64 {
+	switch selection {
+	case "HOME":
+		return m.HomeOdds
+	case "AWAY":
+		return m.AwayOdds
+	case "DRAW":
+		return m.DrawOdds
+	}
+	return 0
+}
+
+// ThreeWay indica si el ganador del partido se ofrece a tres vías (con empate).
+// En un mercado a dos vías un empate anula las apuestas a HOME/AWAY.
+func (m *Match) ThreeWay() bool {
+	return m.DrawOdds > 0 || m.OpeningDrawOdds > 0
+}
+
+// ClosingOddsFor devuelve la cuota de cierre de una selección (0 si aún no se capturó)
+func (m *Match) ClosingOddsFor(selection string) float64 {
+	switch selection {
+	case "HOME":
+		return m.ClosingHomeOdds
+	case "AWAY":
+		return m.ClosingAwayOdds
+	case "DRAW":
+		return m.ClosingDrawOdds
+	}
+	return 0
+}
+
+// TeamFor devuelve el nombre del equipo asociado a una selección.
+func (m *Match) TeamFor(selection string) string {
+	switch selection {
+	case "HOME":
+		return m.HomeTeam
+	case "AWAY":
+		return m.AwayTeam
+	case "DRAW":
+		return "Empate"
+	}
+	return ""
+}
@@ -1,9 +1,11 @@
 package market
 
 import (
-	"fmt"
+	"errors"
+	"log"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -16,22 +18,39 @@ func NewHandler(db *gorm.DB) *Handler {
 }
 
 func (h *Handler) SyncMarketsHandler(c *fiber.Ctx) error {
-	fmt.Println("DEBUG: Iniciando SyncMarketsHandler...") // <-- Log de entrada
-
-	count, err := h.service.SyncEsports()
+	run, err := h.service.SyncEsports(SyncTriggerManual)
 	if err != nil {
-		fmt.Printf("DEBUG ERROR: %v\n", err) // <-- Log de error
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		log.Printf("❌ [MARKET] Error en la sincronización manual: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": err.Error(), "run": run})
 	}
 
-	fmt.Printf("DEBUG: Sincronización finalizada. Partidos: %d\n", count)
+	count := run.Inserted + run.Updated + run.Removed
 	return c.JSON(fiber.Map{
 		"message":         "Sincronización completada",
 		"matches_updated": count,
+		"run":             run,
 	})
 }
 
-// ListMarketsHandler devuelve los partidos desde TU base de datos
+// ListSyncRunsHandler (Endpoint Admin) muestra el historial de sincronizaciones
+// y la última ejecución correcta, para detectar datos desactualizados.
+// @Router /api/admin/sync-runs [get]
+func (h *Handler) ListSyncRunsHandler(c *fiber.Ctx) error {
+	runs, err := h.service.GetSyncRuns(c.QueryInt("limit", 20))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error leyendo el historial de sincronización"})
+	}
+
+	response := fiber.Map{"data": runs, "last_success": nil}
+	if last, err := h.service.GetLastSuccessfulSyncRun(); err == nil {
+		response["last_success"] = last
+	}
+	return c.JSON(response)
+}
+
+// ListMarketsHandler devuelve los partidos desde TU base de datos,
+// con el margen de la casa, las cuotas justas (sin margen) de cada mercado
+// y la probabilidad del modelo Elo para compararla con la casa
 func (h *Handler) ListMarketsHandler(c *fiber.Ctx) error {
 	sport := c.Query("sport") // ?sport=lol
 	matches, err := h.service.GetMatches(sport)
@@ -40,3 +59,110 @@ func (h *Handler) ListMarketsHandler(c *fiber.Ctx) error {
 	}
 	return c.JSON(fiber.Map{"data": matches})
 }
+
+// OddsHistoryHandler devuelve el movimiento de cuotas de un partido
+// @Router /api/markets/{id}/odds-history [get]
+func (h *Handler) OddsHistoryHandler(c *fiber.Ctx) error {
+	matchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ID de partido inválido"})
+	}
+
+	history, err := h.service.GetOddsHistory(matchID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Partido no encontrado"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Error leyendo el historial de cuotas"})
+	}
+
+	return c.JSON(history)
+}
+
+// RecordResultHandler (Endpoint Admin) carga manualmente el resultado de un partido.
+// El worker liquidará las apuestas en su siguiente ciclo.
+// @Router /api/admin/results [post]
+func (h *Handler) RecordResultHandler(c *fiber.Ctx) error {
+	var req RecordResultRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "JSON inválido"})
+	}
+
+	matchID, err := uuid.Parse(req.MatchID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "ID de partido inválido"})
+	}
+
+	result := &MatchResult{
+		MatchID:   matchID,
+		Winner:    req.Winner,
+		HomeScore: req.HomeScore,
+		AwayScore: req.AwayScore,
+		Source:    ResultSourceManual,
+		Confirmed: true,
+	}
+	for _, period := range req.Periods {
+		result.Periods = append(result.Periods, PeriodResult{
+			Period:    period.Period,
+			Winner:    period.Winner,
+			HomeScore: period.HomeScore,
+			AwayScore: period.AwayScore,
+		})
+	}
+	if err := h.service.RecordResult(result); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Partido no encontrado"})
+		}
+		if errors.Is(err, ErrInvalidResult) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"message": "Resultado registrado. Las apuestas se liquidarán automáticamente.",
+		"result":  result,
+	})
+}
+
+// ListRatingsHandler devuelve la clasificación Elo de los equipos.
+// Query: sport (opcional, ej. ?sport=lol).
+// @Router /api/ratings [get]
+func (h *Handler) ListRatingsHandler(c *fiber.Ctx) error {
+	ratings, err := h.service.GetRatings(c.Query("sport"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error leyendo los ratings"})
+	}
+	return c.JSON(fiber.Map{"data": ratings})
+}
+
+// RatingHistoryHandler devuelve la evolución del Elo de un equipo partido a partido.
+// Query: sport y team (obligatorios).
+// @Router /api/ratings/history [get]
+func (h *Handler) RatingHistoryHandler(c *fiber.Ctx) error {
+	sport, team := c.Query("sport"), c.Query("team")
+	if sport == "" || team == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Los parámetros sport y team son obligatorios"})
+	}
+
+	history, err := h.service.GetRatingHistory(sport, team)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error leyendo el historial de ratings"})
+	}
+	return c.JSON(fiber.Map{"sport_key": sport, "team": team, "data": history})
+}
+
+// RebuildRatingsHandler (Endpoint Admin) recalcula todos los ratings desde los resultados liquidados
+// @Router /api/admin/ratings/rebuild [post]
+func (h *Handler) RebuildRatingsHandler(c *fiber.Ctx) error {
+	rated, err := h.service.RebuildRatings()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error recalculando los ratings"})
+	}
+	return c.JSON(fiber.Map{"message": "Ratings recalculados", "matches_rated": rated})
+}
+
+// GetService permite acceder al servicio interno (usado por el worker)
+func (h *Handler) GetService() *Service {
+	return h.service
+}
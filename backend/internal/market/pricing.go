@@ -0,0 +1,108 @@
+package market
+
+import (
+	"math"
+
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
+)
+
+// MarketPricing describe cuánto margen cobra la casa en un mercado y cuáles serían
+// las cuotas justas (sin margen) de cada selección.
+type MarketPricing struct {
+	Margin     float64          `json:"margin"` // Overround (0.045 = 4.5%)
+	Selections []SelectionPrice `json:"selections"`
+}
+
+// SelectionPrice son la probabilidad implícita y la justa de una selección
+type SelectionPrice struct {
+	Selection          string  `json:"selection"`
+	Odds               float64 `json:"odds"`
+	ImpliedProbability float64 `json:"implied_probability"` // 1 / cuota (con margen)
+
+	// Sin margen, método multiplicativo (proporcional)
+	FairProbability float64 `json:"fair_probability"`
+	FairOdds        float64 `json:"fair_odds"`
+
+	// Sin margen, método power
+	FairProbabilityPower float64 `json:"fair_probability_power"`
+	FairOddsPower        float64 `json:"fair_odds_power"`
+}
+
+// PriceMarket calcula el margen y las cuotas justas del ganador del partido
+// (a dos o tres vías). Devuelve nil si falta alguna cuota.
+func (m *Match) PriceMarket() *MarketPricing {
+	if m.DrawOdds > 0 {
+		return priceMarket([]string{"HOME", "DRAW", "AWAY"}, []float64{m.HomeOdds, m.DrawOdds, m.AwayOdds})
+	}
+	return priceMarket([]string{"HOME", "AWAY"}, []float64{m.HomeOdds, m.AwayOdds})
+}
+
+// PriceMarket calcula el margen y las cuotas justas de una línea (dos vías)
+func (l *MarketLine) PriceMarket() *MarketPricing {
+	if l.Type == MarketTypeTotal {
+		return priceMarket([]string{"OVER", "UNDER"}, []float64{l.HomeOdds, l.AwayOdds})
+	}
+	return priceMarket([]string{"HOME", "AWAY"}, []float64{l.HomeOdds, l.AwayOdds})
+}
+
+// ClosingPriceMarket es PriceMarket con las cuotas de cierre.
+// Devuelve nil si el cierre aún no se capturó.
+func (m *Match) ClosingPriceMarket() *MarketPricing {
+	if m.ThreeWay() {
+		return priceMarket([]string{"HOME", "DRAW", "AWAY"}, []float64{m.ClosingHomeOdds, m.ClosingDrawOdds, m.ClosingAwayOdds})
+	}
+	return priceMarket([]string{"HOME", "AWAY"}, []float64{m.ClosingHomeOdds, m.ClosingAwayOdds})
+}
+
+// ClosingPriceMarket es PriceMarket de la línea con las cuotas de cierre (nil si no se capturaron)
+func (l *MarketLine) ClosingPriceMarket() *MarketPricing {
+	if l.Type == MarketTypeTotal {
+		return priceMarket([]string{"OVER", "UNDER"}, []float64{l.ClosingHomeOdds, l.ClosingAwayOdds})
+	}
+	return priceMarket([]string{"HOME", "AWAY"}, []float64{l.ClosingHomeOdds, l.ClosingAwayOdds})
+}
+
+// For devuelve el precio de una selección (nil si no está en el mercado)
+func (p *MarketPricing) For(selection string) *SelectionPrice {
+	if p == nil {
+		return nil
+	}
+	for i := range p.Selections {
+		if p.Selections[i].Selection == selection {
+			return &p.Selections[i]
+		}
+	}
+	return nil
+}
+
+// priceMarket aplica los dos métodos de eliminación de margen a un mercado completo
+func priceMarket(selections []string, odds []float64) *MarketPricing {
+	multiplicative := analytics.FairProbabilities(odds, analytics.VigMethodMultiplicative)
+	power := analytics.FairProbabilities(odds, analytics.VigMethodPower)
+	if multiplicative == nil || power == nil {
+		return nil
+	}
+
+	pricing := &MarketPricing{
+		Margin:     roundTo(analytics.Overround(odds), 4),
+		Selections: make([]SelectionPrice, len(selections)),
+	}
+	for i, selection := range selections {
+		pricing.Selections[i] = SelectionPrice{
+			Selection:            selection,
+			Odds:                 odds[i],
+			ImpliedProbability:   roundTo(analytics.ImpliedProbability(odds[i]), 4),
+			FairProbability:      roundTo(multiplicative[i], 4),
+			FairOdds:             roundTo(1/multiplicative[i], 3),
+			FairProbabilityPower: roundTo(power[i], 4),
+			FairOddsPower:        roundTo(1/power[i], 3),
+		}
+	}
+	return pricing
+}
+
+// roundTo redondea a un número fijo de decimales
+func roundTo(value float64, places int) float64 {
+	factor := math.Pow(10, float64(places))
+	return math.Round(value*factor) / factor
+}
@@ -0,0 +1,86 @@
+package market
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+// ErrCursorRejected indica que el proveedor no acepta el cursor incremental
+// (caducado o inválido); hay que hacer una sincronización completa.
+var ErrCursorRejected = errors.New("cursor de sincronización rechazado")
+
+// ProviderEvent es un partido con sus cuotas, normalizado desde cualquier proveedor
+type ProviderEvent struct {
+	ExternalID string
+	League     string
+	HomeTeam   string
+	AwayTeam   string
+	StartsAt   time.Time
+	HomeOdds   float64
+	AwayOdds   float64
+	DrawOdds   float64        // 0 si el mercado es a dos vías
+	Lines      []ProviderLine // Hándicaps y totales
+
+	// Removed indica que el proveedor retiró el evento (solo en deltas)
+	Removed bool
+}
+
+// ProviderLine es una línea de hándicap o total de un evento
+type ProviderLine struct {
+	Period   int     // 0 = partido completo, N = mapa N
+	Type     string  // MarketTypeMoneyline (solo mapas), MarketTypeSpread o MarketTypeTotal
+	Line     float64 // Hándicap del local o total de puntos
+	HomeOdds float64 // Local u Over
+	AwayOdds float64 // Visitante o Under
+}
+
+// EventBatch es una página de eventos junto con el cursor para pedir el siguiente delta
+type EventBatch struct {
+	Events []ProviderEvent
+	Last   int64 // Cursor 'since' para la próxima llamada (0 si el proveedor no lo soporta)
+	Delta  bool  // true si solo contiene cambios desde el cursor anterior
+}
+
+// ProviderResult es el resultado final de un partido según el proveedor
+type ProviderResult struct {
+	ExternalID string
+	Winner     string // "HOME", "AWAY", "DRAW" o "VOID" (cancelado)
+	HomeScore  int
+	AwayScore  int
+	FinishedAt time.Time
+	Periods    []PeriodResult // Resultado de cada mapa liquidado
+}
+
+// Provider abstrae la fuente de datos de mercado (cuotas y resultados).
+// Pinnacle (RapidAPI) es la implementación de producción; el proveedor de
+// archivos reproduce respuestas grabadas para desarrollo local sin consumir cuota.
+type Provider interface {
+	// Name identifica la fuente; se guarda en Match.Provider y Bet.Provider
+	Name() string
+	// ListEvents devuelve los partidos con sus cuotas actuales. Con since > 0 el
+	// proveedor puede devolver solo los cambios; si no acepta el cursor devuelve ErrCursorRejected.
+	ListEvents(since int64) (*EventBatch, error)
+	// GetOdds devuelve las cuotas actuales de un partido concreto
+	GetOdds(externalID string) (*ProviderEvent, error)
+	// GetResults devuelve los partidos ya liquidados
+	GetResults() ([]ProviderResult, error)
+}
+
+// NewProviderFromEnv elige el proveedor según MARKET_PROVIDER:
+//   - "pinnacle" (por defecto): RapidAPI, requiere RAPIDAPI_KEY
+//   - "file": respuestas grabadas en MARKET_FIXTURES_DIR (por defecto fixtures/pinnacle)
+func NewProviderFromEnv() Provider {
+	switch os.Getenv("MARKET_PROVIDER") {
+	case "file":
+		dir := os.Getenv("MARKET_FIXTURES_DIR")
+		if dir == "" {
+			dir = "fixtures/pinnacle"
+		}
+		log.Println("📁 [MARKET] Usando proveedor de archivos:", dir)
+		return NewFileProvider(dir)
+	default:
+		return NewPinnacleProvider()
+	}
+}
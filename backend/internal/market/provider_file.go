@@ -0,0 +1,63 @@
+package market
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/xnzperez/sports-analytics-backend/internal/integrations/pinnacle"
+)
+
+// FileProvider reproduce respuestas de Pinnacle grabadas en disco.
+// Espera en el directorio:
+//   - markets.json: respuesta de /kit/v1/markets
+//   - archive.json: respuesta de /kit/v1/archive (opcional)
+type FileProvider struct {
+	dir string
+}
+
+func NewFileProvider(dir string) *FileProvider {
+	return &FileProvider{dir: dir}
+}
+
+func (p *FileProvider) Name() string {
+	return "fixture"
+}
+
+// ListEvents siempre devuelve la grabación completa (el cursor se ignora)
+func (p *FileProvider) ListEvents(since int64) (*EventBatch, error) {
+	var resp pinnacle.MarketsResponse
+	if err := p.readJSON("markets.json", &resp); err != nil {
+		return nil, err
+	}
+	return &EventBatch{Events: convertPinnacleEvents(resp.Events), Last: resp.Last}, nil
+}
+
+func (p *FileProvider) GetOdds(externalID string) (*ProviderEvent, error) {
+	batch, err := p.ListEvents(0)
+	if err != nil {
+		return nil, err
+	}
+	return findProviderEvent(batch.Events, externalID)
+}
+
+func (p *FileProvider) GetResults() ([]ProviderResult, error) {
+	var resp pinnacle.ArchiveResponse
+	if err := p.readJSON("archive.json", &resp); err != nil {
+		// Sin archivo de resultados: no hay nada que liquidar
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return convertPinnacleResults(resp.Events), nil
+}
+
+// readJSON lee y decodifica un archivo grabado del directorio
+func (p *FileProvider) readJSON(name string, target interface{}) error {
+	body, err := os.ReadFile(filepath.Join(p.dir, name))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, target)
+}
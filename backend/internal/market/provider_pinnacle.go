@@ -0,0 +1,199 @@
+package market
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xnzperez/sports-analytics-backend/internal/integrations/pinnacle"
+)
+
+// pinnacleTimeLayout es el formato de fecha que usa la API de Pinnacle
+const pinnacleTimeLayout = "2006-01-02T15:04:05"
+
+// PinnacleProvider obtiene mercados y resultados de Pinnacle via RapidAPI
+type PinnacleProvider struct {
+	client *pinnacle.Client
+}
+
+func NewPinnacleProvider() *PinnacleProvider {
+	return &PinnacleProvider{client: pinnacle.NewClient()}
+}
+
+func (p *PinnacleProvider) Name() string {
+	return "pinnacle"
+}
+
+func (p *PinnacleProvider) ListEvents(since int64) (*EventBatch, error) {
+	resp, err := p.client.GetEsportsMarketsSince(since)
+	if err != nil {
+		// Un 4xx en una llamada incremental significa que el cursor ya no es válido
+		var apiErr *pinnacle.APIError
+		if since > 0 && errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 && apiErr.StatusCode != 429 {
+			return nil, fmt.Errorf("%w: %v", ErrCursorRejected, err)
+		}
+		return nil, err
+	}
+	return &EventBatch{
+		Events: convertPinnacleEvents(resp.Events),
+		Last:   resp.Last,
+		Delta:  since > 0,
+	}, nil
+}
+
+func (p *PinnacleProvider) GetOdds(externalID string) (*ProviderEvent, error) {
+	resp, err := p.client.GetEventDetails(externalID)
+	if err != nil {
+		return nil, err
+	}
+	return findProviderEvent(convertPinnacleEvents(resp.Events), externalID)
+}
+
+func (p *PinnacleProvider) GetResults() ([]ProviderResult, error) {
+	resp, err := p.client.GetEsportsResults()
+	if err != nil {
+		return nil, err
+	}
+	return convertPinnacleResults(resp.Events), nil
+}
+
+// convertPinnacleEvents normaliza los eventos de Pinnacle (compartido con el proveedor de archivos)
+func convertPinnacleEvents(events []pinnacle.Event) []ProviderEvent {
+	result := make([]ProviderEvent, 0, len(events))
+	for _, event := range events {
+		startsAt, _ := time.Parse(pinnacleTimeLayout, event.Starts)
+		result = append(result, ProviderEvent{
+			ExternalID: filterNumericID(event.EventID),
+			League:     event.LeagueName,
+			HomeTeam:   event.Home,
+			AwayTeam:   event.Away,
+			StartsAt:   startsAt,
+			HomeOdds:   event.Periods.Num0.MoneyLine.Home,
+			AwayOdds:   event.Periods.Num0.MoneyLine.Away,
+			DrawOdds:   event.Periods.Num0.MoneyLine.Draw,
+			Lines:      convertPinnacleEventLines(event.Periods),
+			Removed:    event.IsHaveOdds != nil && !*event.IsHaveOdds,
+		})
+	}
+	return result
+}
+
+// convertPinnacleEventLines junta las líneas del partido completo y las de cada mapa.
+// Para los mapas se incluye también el ganador (moneyline) como línea propia.
+func convertPinnacleEventLines(periods pinnacle.Periods) []ProviderLine {
+	lines := convertPinnacleLines(0, periods.Num0)
+	for number, period := range periods.Maps() {
+		if period.MoneyLine.Home > 1 && period.MoneyLine.Away > 1 {
+			lines = append(lines, ProviderLine{
+				Period:   number,
+				Type:     MarketTypeMoneyline,
+				HomeOdds: period.MoneyLine.Home,
+				AwayOdds: period.MoneyLine.Away,
+			})
+		}
+		lines = append(lines, convertPinnacleLines(number, period)...)
+	}
+	return lines
+}
+
+// convertPinnacleLines extrae los hándicaps y totales de un periodo.
+// Se descartan las líneas sin precio en alguno de los dos lados.
+func convertPinnacleLines(period int, p pinnacle.Period0) []ProviderLine {
+	var lines []ProviderLine
+	for _, spread := range p.Spreads {
+		if spread.Home <= 1 || spread.Away <= 1 {
+			continue
+		}
+		lines = append(lines, ProviderLine{
+			Period:   period,
+			Type:     MarketTypeSpread,
+			Line:     spread.Hdp,
+			HomeOdds: spread.Home,
+			AwayOdds: spread.Away,
+		})
+	}
+	for _, total := range p.Totals {
+		if total.Over <= 1 || total.Under <= 1 {
+			continue
+		}
+		lines = append(lines, ProviderLine{
+			Period:   period,
+			Type:     MarketTypeTotal,
+			Line:     total.Points,
+			HomeOdds: total.Over,
+			AwayOdds: total.Under,
+		})
+	}
+	return lines
+}
+
+// convertPinnacleResults extrae el resultado del partido completo (periodo 0) y el de
+// cada mapa (periodos 1..N). Solo se devuelven periodos ya liquidados o cancelados por el proveedor.
+func convertPinnacleResults(events []pinnacle.ArchiveEvent) []ProviderResult {
+	var results []ProviderResult
+	for _, event := range events {
+		var result *ProviderResult
+		var maps []PeriodResult
+
+		for _, period := range event.PeriodResults {
+			winner := pinnacleWinner(period)
+			if winner == "" {
+				continue
+			}
+
+			// Mapas: se adjuntan al resultado del partido
+			if period.Number > 0 {
+				maps = append(maps, PeriodResult{
+					Period:    period.Number,
+					Winner:    winner,
+					HomeScore: period.Team1Score,
+					AwayScore: period.Team2Score,
+				})
+				continue
+			}
+
+			finishedAt, _ := time.Parse(pinnacleTimeLayout, period.SettledAt)
+			result = &ProviderResult{
+				ExternalID: filterNumericID(event.EventID),
+				Winner:     winner,
+				HomeScore:  period.Team1Score,
+				AwayScore:  period.Team2Score,
+				FinishedAt: finishedAt,
+			}
+		}
+
+		// Sin resultado del partido completo todavía no se liquida nada
+		if result == nil {
+			continue
+		}
+		result.Periods = maps
+		results = append(results, *result)
+	}
+	return results
+}
+
+// pinnacleWinner traduce el estado de liquidación de un periodo a un ganador
+// ("" si el periodo aún no está liquidado)
+func pinnacleWinner(period pinnacle.PeriodResult) string {
+	switch period.Status {
+	case pinnacle.SettlementSettled, pinnacle.SettlementResettled:
+		// Un periodo liquidado con el marcador igualado es un empate
+		if winner := winnerFromScore(period.Team1Score, period.Team2Score); winner != "" {
+			return winner
+		}
+		return "DRAW"
+	case pinnacle.SettlementCancelled, pinnacle.SettlementResettleCanceled, pinnacle.SettlementDeleted:
+		return "VOID"
+	}
+	return ""
+}
+
+// findProviderEvent busca un partido por su ID externo
+func findProviderEvent(events []ProviderEvent, externalID string) (*ProviderEvent, error) {
+	for i := range events {
+		if events[i].ExternalID == externalID {
+			return &events[i], nil
+		}
+	}
+	return nil, fmt.Errorf("evento %s no encontrado en el proveedor", externalID)
+}
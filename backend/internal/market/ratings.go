@@ -0,0 +1,134 @@
+package market
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
+)
+
+// provisionalRatingMatches es el mínimo de partidos para considerar fiable un rating
+const provisionalRatingMatches = 5
+
+// ModelPrice es la probabilidad que da el modelo Elo a cada equipo, junto a su cuota
+// justa y el valor esperado de apostar al precio actual de la casa.
+// Solo se calcula en mercados a dos vías: Elo no modela la probabilidad de empate.
+type ModelPrice struct {
+	HomeRating      float64 `json:"home_rating"`
+	AwayRating      float64 `json:"away_rating"`
+	HomeProbability float64 `json:"home_probability"`
+	AwayProbability float64 `json:"away_probability"`
+	HomeFairOdds    float64 `json:"home_fair_odds"`
+	AwayFairOdds    float64 `json:"away_fair_odds"`
+	HomeEdge        float64 `json:"home_edge"` // Valor esperado por unidad a la cuota actual (0.05 = +5%)
+	AwayEdge        float64 `json:"away_edge"`
+	Provisional     bool    `json:"provisional"` // Algún equipo tiene pocos partidos calculados
+}
+
+// ApplyResultRating actualiza el Elo de los equipos con un resultado liquidado.
+// Los partidos anulados no puntúan y un partido ya calculado no vuelve a contar.
+func (s *Service) ApplyResultRating(result *MatchResult) error {
+	score, ok := ratingScore(result.Winner)
+	if !ok {
+		return nil
+	}
+
+	match, err := s.repo.GetMatchByID(result.MatchID)
+	if err != nil {
+		return err
+	}
+
+	applied, err := s.repo.ApplyRating(match, score, result.FinishedAt, eloKFactor())
+	if err == nil && applied {
+		log.Printf("📈 [RATINGS] %s vs %s (%s) actualizado", match.HomeTeam, match.AwayTeam, match.SportKey)
+	}
+	return err
+}
+
+// RebuildRatings recalcula todos los ratings desde cero con los resultados ya liquidados,
+// en el orden en que terminaron los partidos. Devuelve cuántos partidos puntuaron.
+func (s *Service) RebuildRatings() (int, error) {
+	return s.repo.RebuildRatings(eloKFactor())
+}
+
+// GetRatings devuelve la clasificación Elo de un juego (todos si sportKey está vacío)
+func (s *Service) GetRatings(sportKey string) ([]TeamRating, error) {
+	return s.repo.GetRatings(sportKey)
+}
+
+// GetRatingHistory devuelve la evolución del rating de un equipo
+func (s *Service) GetRatingHistory(sportKey, team string) ([]RatingHistory, error) {
+	return s.repo.GetRatingHistory(sportKey, team)
+}
+
+// attachModelPrices calcula la probabilidad Elo de cada partido a dos vías.
+// Los equipos sin rating parten del rating inicial y el precio queda como provisional.
+func (s *Service) attachModelPrices(matches []Match) error {
+	ratings, err := s.repo.GetRatings("")
+	if err != nil {
+		return err
+	}
+
+	bySportTeam := make(map[string]TeamRating, len(ratings))
+	for _, rating := range ratings {
+		bySportTeam[rating.SportKey+"|"+rating.Team] = rating
+	}
+	lookup := func(sportKey, team string) (float64, int) {
+		if rating, ok := bySportTeam[sportKey+"|"+team]; ok {
+			return rating.Rating, rating.Matches
+		}
+		return analytics.DefaultEloRating, 0
+	}
+
+	for i := range matches {
+		match := &matches[i]
+		if match.ThreeWay() {
+			continue
+		}
+		homeRating, homeMatches := lookup(match.SportKey, match.HomeTeam)
+		awayRating, awayMatches := lookup(match.SportKey, match.AwayTeam)
+		match.Model = newModelPrice(match, homeRating, awayRating)
+		match.Model.Provisional = homeMatches < provisionalRatingMatches || awayMatches < provisionalRatingMatches
+	}
+	return nil
+}
+
+// newModelPrice arma el precio del modelo a partir de los ratings de ambos equipos
+func newModelPrice(match *Match, homeRating, awayRating float64) *ModelPrice {
+	home := analytics.EloExpected(homeRating, awayRating)
+	away := 1 - home
+	return &ModelPrice{
+		HomeRating:      roundTo(homeRating, 1),
+		AwayRating:      roundTo(awayRating, 1),
+		HomeProbability: roundTo(home, 4),
+		AwayProbability: roundTo(away, 4),
+		HomeFairOdds:    roundTo(1/home, 3),
+		AwayFairOdds:    roundTo(1/away, 3),
+		HomeEdge:        roundTo(analytics.ExpectedValue(home, match.HomeOdds), 4),
+		AwayEdge:        roundTo(analytics.ExpectedValue(away, match.AwayOdds), 4),
+	}
+}
+
+// ratingScore traduce el ganador a la puntuación Elo del local (ok=false si no puntúa)
+func ratingScore(winner string) (float64, bool) {
+	switch winner {
+	case "HOME":
+		return analytics.EloWin, true
+	case "AWAY":
+		return analytics.EloLoss, true
+	case "DRAW":
+		return analytics.EloDraw, true
+	}
+	return 0, false
+}
+
+// eloKFactor lee el factor K del entorno (ELO_K_FACTOR)
+func eloKFactor() float64 {
+	if raw := os.Getenv("ELO_K_FACTOR"); raw != "" {
+		if k, err := strconv.ParseFloat(raw, 64); err == nil && k > 0 {
+			return k
+		}
+	}
+	return analytics.DefaultEloK
+}
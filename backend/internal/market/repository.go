@@ -1,6 +1,12 @@
 package market
 
 import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xnzperez/sports-analytics-backend/internal/analytics"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -14,18 +20,516 @@ func NewRepository(db *gorm.DB) *Repository {
 }
 
 // SaveMatch guarda el partido traído de la API.
-// Usa Clauses(clause.OnConflict) para evitar errores si sincronizas dos veces.
-func (r *Repository) SaveMatch(match *Match) error {
-	return r.db.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "external_id"}},                                    // Si el ID de Pinnacle ya existe...
-		DoUpdates: clause.AssignmentColumns([]string{"home_odds", "away_odds", "starts_at"}), // Actualiza cuotas y fecha
-	}).Create(match).Error
+// Si ya existe (mismo external_id) actualiza cuotas y fecha; si estaba retirado
+// y vuelve a ofrecerse, recupera el estado 'scheduled'.
+// Cada precio nuevo queda registrado en odds_snapshots (historial de la línea).
+// Devuelve si se insertó y si cambió algún dato de un partido existente.
+func (r *Repository) SaveMatch(match *Match) (inserted bool, changed bool, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		var existing Match
+		findErr := tx.Where("external_id = ?", match.ExternalID).First(&existing).Error
+
+		// A. Partido nuevo: el primer precio es el de apertura
+		if errors.Is(findErr, gorm.ErrRecordNotFound) {
+			match.OpeningHomeOdds = match.HomeOdds
+			match.OpeningAwayOdds = match.AwayOdds
+			match.OpeningDrawOdds = match.DrawOdds
+			if err := tx.Create(match).Error; err != nil {
+				return err
+			}
+			inserted, changed = true, true
+			return r.saveSnapshotTx(tx, match)
+		}
+		if findErr != nil {
+			return findErr
+		}
+
+		// B. Partido existente: solo escribimos si algo cambió
+		match.ID = existing.ID
+		updates := map[string]interface{}{}
+		oddsChanged := existing.HomeOdds != match.HomeOdds || existing.AwayOdds != match.AwayOdds ||
+			existing.DrawOdds != match.DrawOdds
+		if oddsChanged {
+			updates["home_odds"] = match.HomeOdds
+			updates["away_odds"] = match.AwayOdds
+			updates["draw_odds"] = match.DrawOdds
+		}
+		// Partidos guardados antes de registrar la apertura
+		if existing.OpeningHomeOdds == 0 {
+			updates["opening_home_odds"] = existing.HomeOdds
+			updates["opening_away_odds"] = existing.AwayOdds
+			updates["opening_draw_odds"] = existing.DrawOdds
+		}
+		if !existing.StartsAt.Equal(match.StartsAt) {
+			updates["starts_at"] = match.StartsAt
+		}
+		if existing.Status == "removed" {
+			updates["status"] = "scheduled"
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+
+		changed = true
+		if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+			return err
+		}
+		if oddsChanged {
+			return r.saveSnapshotTx(tx, match)
+		}
+		return nil
+	})
+
+	return inserted, changed, err
+}
+
+// SaveLines reemplaza las líneas de un partido por las recibidas del proveedor:
+// actualiza precios de las existentes, crea las nuevas y retira las que ya no se ofrecen.
+// En un delta (full=false) el proveedor solo envía los mercados que cambiaron, así que
+// solo se retiran las líneas ausentes de un mapa y tipo de mercado presentes en 'lines'.
+// Devuelve si cambió algo.
+func (r *Repository) SaveLines(matchID uuid.UUID, lines []MarketLine, full bool) (changed bool, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		var existing []MarketLine
+		if err := tx.Where("match_id = ?", matchID).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		type lineKey struct {
+			Period int
+			Type   string
+			Line   float64
+		}
+		current := make(map[lineKey]MarketLine, len(existing))
+		for _, line := range existing {
+			current[lineKey{line.Period, line.Type, line.Line}] = line
+		}
+
+		type marketKey struct {
+			Period int
+			Type   string
+		}
+
+		// 1. Altas y cambios de precio
+		seen := make(map[lineKey]bool, len(lines))
+		seenMarkets := make(map[marketKey]bool)
+		for _, line := range lines {
+			key := lineKey{line.Period, line.Type, line.Line}
+			seen[key] = true
+			seenMarkets[marketKey{line.Period, line.Type}] = true
+
+			old, ok := current[key]
+			if !ok {
+				line.MatchID = matchID
+				line.Status = "open"
+				if err := tx.Create(&line).Error; err != nil {
+					return err
+				}
+				changed = true
+				continue
+			}
+			if old.HomeOdds == line.HomeOdds && old.AwayOdds == line.AwayOdds && old.Status == "open" {
+				continue
+			}
+			if err := tx.Model(&old).Updates(map[string]interface{}{
+				"home_odds": line.HomeOdds,
+				"away_odds": line.AwayOdds,
+				"status":    "open",
+			}).Error; err != nil {
+				return err
+			}
+			changed = true
+		}
+
+		// 2. Bajas: las líneas que el proveedor ya no ofrece
+		for key, old := range current {
+			if seen[key] || old.Status == "removed" {
+				continue
+			}
+			if !full && !seenMarkets[marketKey{key.Period, key.Type}] {
+				continue
+			}
+			if err := tx.Model(&old).Update("status", "removed").Error; err != nil {
+				return err
+			}
+			changed = true
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// GetMarketLine busca una línea por su UUID
+func (r *Repository) GetMarketLine(id uuid.UUID) (*MarketLine, error) {
+	var line MarketLine
+	if err := r.db.First(&line, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
+
+// saveSnapshotTx registra el precio actual del partido en el historial
+func (r *Repository) saveSnapshotTx(tx *gorm.DB, match *Match) error {
+	return tx.Create(&OddsSnapshot{
+		MatchID:    match.ID,
+		HomeOdds:   match.HomeOdds,
+		AwayOdds:   match.AwayOdds,
+		DrawOdds:   match.DrawOdds,
+		CapturedAt: time.Now(),
+	}).Error
+}
+
+// GetOddsHistory devuelve la serie de precios de un partido en orden cronológico
+func (r *Repository) GetOddsHistory(matchID uuid.UUID) ([]OddsSnapshot, error) {
+	var snapshots []OddsSnapshot
+	err := r.db.Where("match_id = ?", matchID).Order("captured_at asc").Find(&snapshots).Error
+	return snapshots, err
+}
+
+// MarkMatchRemoved marca como retirado un partido que el proveedor dejó de ofrecer
+func (r *Repository) MarkMatchRemoved(provider, externalID string) error {
+	return r.db.Model(&Match{}).
+		Where("provider = ? AND external_id = ? AND status = ?", provider, externalID, "scheduled").
+		Update("status", "removed").Error
+}
+
+// MarkMissingRemoved retira los partidos futuros del proveedor que no aparecen
+// en una sincronización completa. Devuelve cuántos se retiraron.
+func (r *Repository) MarkMissingRemoved(provider string, seenExternalIDs []string) (int64, error) {
+	// Una respuesta vacía suele ser un fallo del proveedor: no borramos el mercado entero
+	if len(seenExternalIDs) == 0 {
+		return 0, nil
+	}
+	result := r.db.Model(&Match{}).
+		Where("provider = ? AND status = ? AND starts_at > ?", provider, "scheduled", time.Now()).
+		Where("external_id NOT IN ?", seenExternalIDs).
+		Update("status", "removed")
+	return result.RowsAffected, result.Error
 }
 
-// GetMatches devuelve la lista para que el frontend la vea
+// GetMatches devuelve la lista para que el frontend la vea (sin partidos retirados)
 func (r *Repository) GetMatches() ([]Match, error) {
 	var matches []Match
 	// Ordenamos por fecha de inicio
-	result := r.db.Order("starts_at asc").Find(&matches)
+	result := r.db.Preload("Lines", "status = ?", "open").
+		Where("status <> ?", "removed").Order("starts_at asc").Find(&matches)
 	return matches, result.Error
 }
+
+// GetSyncCursor devuelve el último cursor guardado para un proveedor y deporte
+func (r *Repository) GetSyncCursor(provider string, sportID int) (*SyncCursor, error) {
+	var cursor SyncCursor
+	if err := r.db.First(&cursor, "provider = ? AND sport_id = ?", provider, sportID).Error; err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// SaveSyncCursor guarda (o actualiza) el cursor de sincronización
+func (r *Repository) SaveSyncCursor(cursor *SyncCursor) error {
+	return r.db.Save(cursor).Error
+}
+
+// GetMatchByID busca un partido por su UUID interno
+func (r *Repository) GetMatchByID(id uuid.UUID) (*Match, error) {
+	var match Match
+	if err := r.db.First(&match, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// GetMatchByExternalID busca un partido por el ID del proveedor
+func (r *Repository) GetMatchByExternalID(externalID string) (*Match, error) {
+	var match Match
+	if err := r.db.First(&match, "external_id = ?", externalID).Error; err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// SaveResult registra (o corrige) el resultado de un partido y de sus mapas y lo marca como finalizado.
+// Un resultado ya liquidado no se sobrescribe para no descuadrar el ledger.
+func (r *Repository) SaveResult(result *MatchResult) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing MatchResult
+		if err := tx.First(&existing, "match_id = ?", result.MatchID).Error; err == nil && existing.SettledAt != nil {
+			return nil
+		}
+
+		// Los mapas se guardan aparte (upsert por partido y número de mapa)
+		periods := result.Periods
+		if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "match_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"winner", "home_score", "away_score", "finished_at", "source", "confirmed", "updated_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "match_results.settled_at IS NULL"},
+			}},
+		}).Create(result).Error; err != nil {
+			return err
+		}
+
+		for i := range periods {
+			periods[i].MatchID = result.MatchID
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "match_id"}, {Name: "period"}},
+				DoUpdates: clause.AssignmentColumns([]string{"winner", "home_score", "away_score", "updated_at"}),
+			}).Create(&periods[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&Match{}).Where("id = ?", result.MatchID).Update("status", "finished").Error
+	})
+}
+
+// GetResult devuelve el resultado registrado de un partido
+func (r *Repository) GetResult(matchID uuid.UUID) (*MatchResult, error) {
+	var result MatchResult
+	if err := r.db.Preload("Periods").First(&result, "match_id = ?", matchID).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetUnsettledResults devuelve los resultados confirmados cuyas apuestas aún no se liquidaron
+func (r *Repository) GetUnsettledResults() ([]MatchResult, error) {
+	var results []MatchResult
+	err := r.db.Preload("Periods").Where("confirmed = ? AND settled_at IS NULL", true).
+		Order("finished_at asc").
+		Find(&results).Error
+	return results, err
+}
+
+// MarkResultSettled marca un resultado como liquidado
+func (r *Repository) MarkResultSettled(resultID uuid.UUID) error {
+	return r.db.Model(&MatchResult{}).Where("id = ?", resultID).Update("settled_at", time.Now()).Error
+}
+
+// CreateSyncRun guarda el inicio de una sincronización
+func (r *Repository) CreateSyncRun(run *SyncRun) error {
+	return r.db.Create(run).Error
+}
+
+// FinishSyncRun guarda los contadores y el error final de una sincronización
+func (r *Repository) FinishSyncRun(run *SyncRun) error {
+	return r.db.Save(run).Error
+}
+
+// GetSyncRuns devuelve las últimas ejecuciones, de la más reciente a la más antigua
+func (r *Repository) GetSyncRuns(limit int) ([]SyncRun, error) {
+	var runs []SyncRun
+	err := r.db.Order("started_at desc").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// GetLastSuccessfulSyncRun devuelve la última sincronización terminada sin error
+func (r *Repository) GetLastSuccessfulSyncRun() (*SyncRun, error) {
+	var run SyncRun
+	err := r.db.Where("finished_at IS NOT NULL AND (error IS NULL OR error = '')").
+		Order("started_at desc").
+		First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// CaptureClosingOdds congela como precio de cierre la última cuota de los partidos
+// que ya comenzaron. Devuelve los partidos capturados en esta llamada.
+func (r *Repository) CaptureClosingOdds(now time.Time) ([]Match, error) {
+	var matches []Match
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Lines").
+			Where("starts_at <= ? AND closing_captured_at IS NULL AND status <> ?", now, "removed").
+			Where("home_odds > 0 AND away_odds > 0").
+			Find(&matches).Error; err != nil {
+			return err
+		}
+
+		for i := range matches {
+			matches[i].ClosingHomeOdds = matches[i].HomeOdds
+			matches[i].ClosingAwayOdds = matches[i].AwayOdds
+			matches[i].ClosingDrawOdds = matches[i].DrawOdds
+			matches[i].ClosingCapturedAt = &now
+			if err := tx.Model(&matches[i]).Updates(map[string]interface{}{
+				"closing_home_odds":   matches[i].ClosingHomeOdds,
+				"closing_away_odds":   matches[i].ClosingAwayOdds,
+				"closing_draw_odds":   matches[i].ClosingDrawOdds,
+				"closing_captured_at": now,
+			}).Error; err != nil {
+				return err
+			}
+
+			// Las líneas adicionales cierran en el mismo momento
+			for j := range matches[i].Lines {
+				line := &matches[i].Lines[j]
+				line.ClosingHomeOdds = line.HomeOdds
+				line.ClosingAwayOdds = line.AwayOdds
+			}
+			if err := tx.Model(&MarketLine{}).Where("match_id = ?", matches[i].ID).Updates(map[string]interface{}{
+				"closing_home_odds": gorm.Expr("home_odds"),
+				"closing_away_odds": gorm.Expr("away_odds"),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// ratingsLockKey identifica el bloqueo consultivo que serializa los cambios de ratings
+const ratingsLockKey = 727001
+
+// lockRatingsTx bloquea los ratings hasta el final de la transacción, para que un
+// recálculo completo y la actualización del worker no se mezclen
+func lockRatingsTx(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", ratingsLockKey).Error
+}
+
+// ApplyRating actualiza el Elo de los dos equipos de un partido y guarda el historial.
+// homeScore es 1 si ganó el local, 0.5 si empataron y 0 si ganó el visitante.
+// Devuelve applied=false si el partido ya había puntuado (la operación es idempotente).
+func (r *Repository) ApplyRating(match *Match, homeScore float64, playedAt time.Time, k float64) (applied bool, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockRatingsTx(tx); err != nil {
+			return err
+		}
+		applied, err = applyRatingTx(tx, match, homeScore, playedAt, k)
+		return err
+	})
+	return applied, err
+}
+
+// RebuildRatings borra todos los ratings y los recalcula con los resultados liquidados,
+// en el orden en que terminaron los partidos. Todo ocurre en una sola transacción:
+// si algo falla se conservan los ratings anteriores. Devuelve cuántos partidos puntuaron.
+func (r *Repository) RebuildRatings(k float64) (rated int, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		rated = 0
+		if err := lockRatingsTx(tx); err != nil {
+			return err
+		}
+
+		// 1. Borrar ratings e historial
+		if err := tx.Where("1 = 1").Delete(&RatingHistory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("1 = 1").Delete(&TeamRating{}).Error; err != nil {
+			return err
+		}
+
+		// 2. Volver a aplicar cada resultado liquidado
+		var results []MatchResult
+		if err := tx.Where("settled_at IS NOT NULL").
+			Order("finished_at asc").
+			Find(&results).Error; err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			score, ok := ratingScore(result.Winner)
+			if !ok {
+				continue
+			}
+			var match Match
+			if err := tx.First(&match, "id = ?", result.MatchID).Error; err != nil {
+				log.Printf("⚠️  [RATINGS] Partido %s no encontrado: %v", result.MatchID, err)
+				continue
+			}
+			applied, err := applyRatingTx(tx, &match, score, result.FinishedAt, k)
+			if err != nil {
+				return err
+			}
+			if applied {
+				rated++
+			}
+		}
+		return nil
+	})
+	return rated, err
+}
+
+// applyRatingTx aplica un resultado dentro de una transacción con los ratings ya bloqueados
+func applyRatingTx(tx *gorm.DB, match *Match, homeScore float64, playedAt time.Time, k float64) (bool, error) {
+	var count int64
+	if err := tx.Model(&RatingHistory{}).Where("match_id = ?", match.ID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	home, err := ratingForUpdateTx(tx, match.SportKey, match.HomeTeam)
+	if err != nil {
+		return false, err
+	}
+	away, err := ratingForUpdateTx(tx, match.SportKey, match.AwayTeam)
+	if err != nil {
+		return false, err
+	}
+
+	newHome, newAway := analytics.EloUpdate(home.Rating, away.Rating, homeScore, k)
+	history := []RatingHistory{
+		{
+			SportKey: match.SportKey, Team: home.Team, MatchID: match.ID, Opponent: away.Team,
+			RatingBefore: home.Rating, RatingAfter: newHome,
+			Expected: analytics.EloExpected(home.Rating, away.Rating), Score: homeScore, PlayedAt: playedAt,
+		},
+		{
+			SportKey: match.SportKey, Team: away.Team, MatchID: match.ID, Opponent: home.Team,
+			RatingBefore: away.Rating, RatingAfter: newAway,
+			Expected: analytics.EloExpected(away.Rating, home.Rating), Score: 1 - homeScore, PlayedAt: playedAt,
+		},
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return false, err
+	}
+
+	home.Rating, away.Rating = newHome, newAway
+	home.Matches++
+	away.Matches++
+	if err := tx.Save(home).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Save(away).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ratingForUpdateTx bloquea el rating de un equipo o devuelve uno inicial si aún no tiene
+func ratingForUpdateTx(tx *gorm.DB, sportKey, team string) (*TeamRating, error) {
+	var rating TeamRating
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&rating, "sport_key = ? AND team = ?", sportKey, team).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &TeamRating{SportKey: sportKey, Team: team, Rating: analytics.DefaultEloRating}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rating, nil
+}
+
+// GetRatings devuelve los ratings de un juego (todos si sportKey está vacío), de mayor a menor
+func (r *Repository) GetRatings(sportKey string) ([]TeamRating, error) {
+	var ratings []TeamRating
+	query := r.db.Order("rating desc")
+	if sportKey != "" {
+		query = query.Where("sport_key = ?", sportKey)
+	}
+	err := query.Find(&ratings).Error
+	return ratings, err
+}
+
+// GetRatingHistory devuelve la evolución del rating de un equipo, del partido más antiguo al más reciente
+func (r *Repository) GetRatingHistory(sportKey, team string) ([]RatingHistory, error) {
+	var history []RatingHistory
+	err := r.db.Where("sport_key = ? AND team = ?", sportKey, team).
+		Order("played_at asc").
+		Find(&history).Error
+	return history, err
+}
@@ -0,0 +1,158 @@
+package market
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidResult indica que los datos del resultado no son coherentes
+var ErrInvalidResult = errors.New("resultado inválido")
+
+// RecordResultRequest es el JSON del endpoint admin para cargar resultados manuales
+type RecordResultRequest struct {
+	MatchID   string `json:"match_id"`
+	Winner    string `json:"winner"` // Opcional: se deduce del marcador. "DRAW" si hubo empate, "VOID" si se canceló
+	HomeScore int    `json:"home_score"`
+	AwayScore int    `json:"away_score"`
+
+	// Opcional: resultado de cada mapa, para liquidar los mercados por mapa
+	Periods []PeriodResultRequest `json:"periods"`
+}
+
+// PeriodResultRequest es el resultado de un mapa dentro de RecordResultRequest
+type PeriodResultRequest struct {
+	Period    int    `json:"period"` // 1 = primer mapa
+	Winner    string `json:"winner"` // Opcional: se deduce del marcador
+	HomeScore int    `json:"home_score"`
+	AwayScore int    `json:"away_score"`
+}
+
+// RecordResult valida y guarda el resultado de un partido.
+// Si result.Winner viene vacío se deduce del marcador; un marcador igualado es
+// empate (DRAW), salvo un 0-0 en un mercado a dos vías, que se trata como "sin marcador".
+func (s *Service) RecordResult(result *MatchResult) error {
+	// 1. El partido debe existir
+	match, err := s.repo.GetMatchByID(result.MatchID)
+	if err != nil {
+		return err
+	}
+
+	// 2. Determinar el ganador
+	if result.Winner == "" {
+		result.Winner = winnerFromScore(result.HomeScore, result.AwayScore)
+		if result.Winner == "" && (result.HomeScore > 0 || match.ThreeWay()) {
+			result.Winner = "DRAW"
+		}
+	}
+	if !validWinner(result.Winner) {
+		return fmt.Errorf("%w: no se puede determinar el ganador", ErrInvalidResult)
+	}
+	if result.HomeScore < 0 || result.AwayScore < 0 {
+		return fmt.Errorf("%w: el marcador no puede ser negativo", ErrInvalidResult)
+	}
+	if result.FinishedAt.IsZero() {
+		result.FinishedAt = time.Now()
+	}
+
+	// 3. Validar los mapas
+	seen := make(map[int]bool)
+	for i := range result.Periods {
+		period := &result.Periods[i]
+		if period.Period <= 0 || seen[period.Period] {
+			return fmt.Errorf("%w: número de mapa inválido o repetido", ErrInvalidResult)
+		}
+		seen[period.Period] = true
+
+		if period.Winner == "" {
+			period.Winner = winnerFromScore(period.HomeScore, period.AwayScore)
+			if period.Winner == "" && period.HomeScore > 0 {
+				period.Winner = "DRAW"
+			}
+		}
+		if !validWinner(period.Winner) {
+			return fmt.Errorf("%w: no se puede determinar el ganador del mapa %d", ErrInvalidResult, period.Period)
+		}
+		if period.HomeScore < 0 || period.AwayScore < 0 {
+			return fmt.Errorf("%w: el marcador del mapa %d no puede ser negativo", ErrInvalidResult, period.Period)
+		}
+		period.MatchID = result.MatchID
+	}
+
+	// 4. Guardar (y marcar el partido como finalizado)
+	return s.repo.SaveResult(result)
+}
+
+// validWinner comprueba que el ganador sea uno de los valores admitidos
+func validWinner(winner string) bool {
+	switch winner {
+	case "HOME", "AWAY", "DRAW", "VOID":
+		return true
+	}
+	return false
+}
+
+// winnerFromScore deduce el ganador del marcador ("" si hay empate)
+func winnerFromScore(homeScore, awayScore int) string {
+	switch {
+	case homeScore > awayScore:
+		return "HOME"
+	case awayScore > homeScore:
+		return "AWAY"
+	}
+	return ""
+}
+
+// SyncResults descarga los eventos liquidados del proveedor y registra sus resultados.
+// Devuelve cuántos resultados se registraron.
+func (s *Service) SyncResults() (int, error) {
+	results, err := s.provider.GetResults()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, providerResult := range results {
+		// Solo nos interesan partidos que tenemos en el mercado
+		match, err := s.repo.GetMatchByExternalID(providerResult.ExternalID)
+		if err != nil {
+			continue
+		}
+
+		result := &MatchResult{
+			MatchID:    match.ID,
+			Winner:     providerResult.Winner,
+			HomeScore:  providerResult.HomeScore,
+			AwayScore:  providerResult.AwayScore,
+			FinishedAt: providerResult.FinishedAt,
+			Periods:    providerResult.Periods,
+			Source:     ResultSourceProvider,
+			Confirmed:  true,
+		}
+		if err := s.RecordResult(result); err != nil {
+			log.Printf("⚠️  Resultado de %s no registrado: %v", match.ExternalID, err)
+			continue
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// GetResult devuelve el resultado registrado de un partido
+func (s *Service) GetResult(matchID uuid.UUID) (*MatchResult, error) {
+	return s.repo.GetResult(matchID)
+}
+
+// GetUnsettledResults devuelve los resultados confirmados pendientes de liquidar
+func (s *Service) GetUnsettledResults() ([]MatchResult, error) {
+	return s.repo.GetUnsettledResults()
+}
+
+// MarkResultSettled marca que las apuestas de un resultado ya se liquidaron
+func (s *Service) MarkResultSettled(resultID uuid.UUID) error {
+	return s.repo.MarkResultSettled(resultID)
+}
@@ -1,88 +1,278 @@
 package market
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/xnzperez/sports-analytics-backend/internal/integrations/pinnacle"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type Service struct {
-	repo           *Repository // CAMBIO: Ahora usamos el Repository en lugar de db directo
-	pinnacleClient *pinnacle.Client
+	repo     *Repository // CAMBIO: Ahora usamos el Repository en lugar de db directo
+	provider Provider    // Fuente de cuotas y resultados (ver NewProviderFromEnv)
 }
 
 func NewService(db *gorm.DB) *Service {
 	return &Service{
-		repo:           NewRepository(db), // Inicializamos el Repo
-		pinnacleClient: pinnacle.NewClient(),
+		repo:     NewRepository(db), // Inicializamos el Repo
+		provider: NewProviderFromEnv(),
 	}
 }
 
-// SyncEsports llama a la API y actualiza nuestra base de datos local usando el Repo
-func (s *Service) SyncEsports() (int, error) {
-	// 1. Llamar a RapidAPI
-	resp, err := s.pinnacleClient.GetEsportsMarkets()
+// esportsSportID es el ID de Esports en Pinnacle; se usa como clave del cursor
+const esportsSportID = 10
+
+// defaultCursorMaxAge es la antigüedad máxima de un cursor antes de forzar una
+// sincronización completa, si no se configura MARKET_CURSOR_MAX_AGE_MINUTES.
+const defaultCursorMaxAge = 30 * time.Minute
+
+// SyncEsports llama al proveedor y actualiza nuestra base de datos local usando el Repo.
+// Si hay un cursor reciente pide solo los cambios (delta); si el cursor es muy antiguo
+// o el proveedor lo rechaza, hace una sincronización completa.
+// Cada ejecución queda registrada en sync_runs (trigger: scheduler o manual).
+func (s *Service) SyncEsports(trigger string) (*SyncRun, error) {
+	run := &SyncRun{
+		Provider:  s.provider.Name(),
+		Trigger:   trigger,
+		StartedAt: time.Now(),
+	}
+	if err := s.repo.CreateSyncRun(run); err != nil {
+		log.Println("⚠️  [MARKET] No se pudo registrar la sincronización:", err)
+	}
+
+	syncErr := s.syncEvents(run)
+
+	// Cerrar el registro de la ejecución (con o sin error)
+	now := time.Now()
+	run.FinishedAt = &now
+	if syncErr != nil {
+		run.Error = syncErr.Error()
+	}
+	if err := s.repo.FinishSyncRun(run); err != nil {
+		log.Println("⚠️  [MARKET] No se pudo guardar la sincronización:", err)
+	}
+
+	return run, syncErr
+}
+
+// syncEvents descarga los eventos y aplica los cambios, acumulando contadores en run
+func (s *Service) syncEvents(run *SyncRun) error {
+	// 1. Decidir entre delta y sincronización completa
+	since := int64(0)
+	cursor, err := s.repo.GetSyncCursor(s.provider.Name(), esportsSportID)
+	if err == nil && cursor.Last > 0 && time.Since(cursor.UpdatedAt) < cursorMaxAge() {
+		since = cursor.Last
+	}
+
+	// 2. Llamar al proveedor configurado
+	batch, err := s.provider.ListEvents(since)
+	if errors.Is(err, ErrCursorRejected) {
+		log.Println("⚠️  [MARKET] Cursor rechazado, sincronización completa:", err)
+		batch, err = s.provider.ListEvents(0)
+	}
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	count := 0
+	run.Mode = SyncModeFull
+	if batch.Delta {
+		run.Mode = SyncModeDelta
+	}
+	run.EventsSeen = len(batch.Events)
+	seen := make([]string, 0, len(batch.Events))
 
-	// 2. Procesar cada evento
-	for _, event := range resp.Events {
+	// 3. Procesar cada evento
+	for _, event := range batch.Events {
 
-		// Filtrar cuotas vacías (0.00)
-		if event.Periods.Num0.MoneyLine.Home == 0 || event.Periods.Num0.MoneyLine.Away == 0 {
+		// Evento retirado por el proveedor
+		if event.Removed {
+			if err := s.repo.MarkMatchRemoved(s.provider.Name(), event.ExternalID); err == nil {
+				run.Removed++
+			}
 			continue
 		}
 
-		// Inferir deporte
-		sportKey := "esports"
-		leagueLower := strings.ToLower(event.LeagueName)
-		if strings.Contains(leagueLower, "lol") || strings.Contains(leagueLower, "league of legends") || strings.Contains(leagueLower, "lck") || strings.Contains(leagueLower, "lpl") {
-			sportKey = "lol"
-		} else if strings.Contains(leagueLower, "dota") {
-			sportKey = "dota2"
-		} else if strings.Contains(leagueLower, "cs2") || strings.Contains(leagueLower, "counter-strike") {
-			sportKey = "cs2"
-		} else if strings.Contains(leagueLower, "valorant") {
-			sportKey = "valorant"
+		// Filtrar cuotas vacías (0.00)
+		if event.HomeOdds == 0 || event.AwayOdds == 0 {
+			run.Skipped++
+			continue
 		}
-
-		// Parsear fecha
-		startTime, _ := time.Parse("2006-01-02T15:04:05", event.Starts)
+		seen = append(seen, event.ExternalID)
 
 		match := Match{
-			ExternalID: filterNumericID(event.EventID),
-			Provider:   "pinnacle",
-			SportKey:   sportKey,
-			League:     event.LeagueName,
-			HomeTeam:   event.Home,
-			AwayTeam:   event.Away,
-			StartsAt:   startTime,
-			HomeOdds:   event.Periods.Num0.MoneyLine.Home,
-			AwayOdds:   event.Periods.Num0.MoneyLine.Away,
+			ExternalID: event.ExternalID,
+			Provider:   s.provider.Name(),
+			SportKey:   sportKeyFromLeague(event.League),
+			League:     event.League,
+			HomeTeam:   event.HomeTeam,
+			AwayTeam:   event.AwayTeam,
+			StartsAt:   event.StartsAt,
+			HomeOdds:   event.HomeOdds,
+			AwayOdds:   event.AwayOdds,
+			DrawOdds:   event.DrawOdds,
 			Status:     "scheduled",
 		}
 
 		// CAMBIO: Usamos el repositorio para guardar
-		if err := s.repo.SaveMatch(&match); err == nil {
-			count++
+		inserted, changed, err := s.repo.SaveMatch(&match)
+		if err == nil {
+			// Hándicaps y totales del partido (en un delta solo se retiran líneas de los mercados recibidos)
+			linesChanged, linesErr := s.repo.SaveLines(match.ID, toMarketLines(event.Lines, match.SportKey), !batch.Delta)
+			if linesErr != nil {
+				log.Printf("⚠️  [MARKET] Líneas de %s no guardadas: %v", event.ExternalID, linesErr)
+			}
+			changed = changed || linesChanged
+		}
+		switch {
+		case err != nil, !changed:
+			run.Skipped++
+		case inserted:
+			run.Inserted++
+		default:
+			run.Updated++
 		}
 	}
 
-	return count, nil
+	// 4. En una sincronización completa, lo que no vino ya no se ofrece
+	if !batch.Delta {
+		removed, err := s.repo.MarkMissingRemoved(s.provider.Name(), seen)
+		if err != nil {
+			return err
+		}
+		run.Removed += int(removed)
+	}
+
+	// 5. Guardar el cursor para la próxima llamada
+	if batch.Last > 0 {
+		return s.repo.SaveSyncCursor(&SyncCursor{
+			Provider: s.provider.Name(),
+			SportID:  esportsSportID,
+			Last:     batch.Last,
+		})
+	}
+
+	return nil
+}
+
+// mapMarketSports son los juegos para los que ofrecemos mercados por mapa
+var mapMarketSports = map[string]bool{"lol": true, "cs2": true, "dota2": true, "valorant": true}
+
+// toMarketLines convierte las líneas del proveedor al modelo de base de datos.
+// Los mercados por mapa solo se guardan para los juegos de mapMarketSports.
+func toMarketLines(providerLines []ProviderLine, sportKey string) []MarketLine {
+	lines := make([]MarketLine, 0, len(providerLines))
+	for _, line := range providerLines {
+		if line.Period > 0 && !mapMarketSports[sportKey] {
+			continue
+		}
+		lines = append(lines, MarketLine{
+			Period:   line.Period,
+			Type:     line.Type,
+			Line:     line.Line,
+			HomeOdds: line.HomeOdds,
+			AwayOdds: line.AwayOdds,
+		})
+	}
+	return lines
+}
+
+// GetSyncRuns devuelve el historial de sincronizaciones
+func (s *Service) GetSyncRuns(limit int) ([]SyncRun, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.repo.GetSyncRuns(limit)
+}
+
+// GetLastSuccessfulSyncRun devuelve la última sincronización correcta
+func (s *Service) GetLastSuccessfulSyncRun() (*SyncRun, error) {
+	return s.repo.GetLastSuccessfulSyncRun()
+}
+
+// cursorMaxAge lee la antigüedad máxima del cursor desde el entorno
+func cursorMaxAge() time.Duration {
+	if raw := os.Getenv("MARKET_CURSOR_MAX_AGE_MINUTES"); raw != "" {
+		if minutes, err := strconv.Atoi(raw); err == nil && minutes > 0 {
+			return time.Duration(minutes) * time.Minute
+		}
+	}
+	return defaultCursorMaxAge
+}
+
+// OddsHistoryResponse es la serie de precios de un partido para graficar el movimiento de línea
+type OddsHistoryResponse struct {
+	MatchID uuid.UUID      `json:"match_id"`
+	Opening OddsPoint      `json:"opening"`
+	Latest  OddsPoint      `json:"latest"`
+	Data    []OddsSnapshot `json:"data"`
+}
+
+// OddsPoint son los precios del ganador (local / visitante / empate)
+type OddsPoint struct {
+	HomeOdds float64 `json:"home_odds"`
+	AwayOdds float64 `json:"away_odds"`
+	DrawOdds float64 `json:"draw_odds,omitempty"`
+}
+
+// GetOddsHistory devuelve el historial de precios de un partido junto con su apertura y último precio
+func (s *Service) GetOddsHistory(matchID uuid.UUID) (*OddsHistoryResponse, error) {
+	match, err := s.repo.GetMatchByID(matchID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, err := s.repo.GetOddsHistory(matchID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OddsHistoryResponse{
+		MatchID: match.ID,
+		Opening: OddsPoint{HomeOdds: match.OpeningHomeOdds, AwayOdds: match.OpeningAwayOdds, DrawOdds: match.OpeningDrawOdds},
+		Latest:  OddsPoint{HomeOdds: match.HomeOdds, AwayOdds: match.AwayOdds, DrawOdds: match.DrawOdds},
+		Data:    snapshots,
+	}, nil
+}
+
+// GetMatch devuelve un partido por su UUID
+func (s *Service) GetMatch(matchID uuid.UUID) (*Match, error) {
+	return s.repo.GetMatchByID(matchID)
+}
+
+// CaptureClosingOdds fija el precio de cierre de los partidos que acaban de comenzar
+func (s *Service) CaptureClosingOdds() ([]Match, error) {
+	return s.repo.CaptureClosingOdds(time.Now())
 }
 
 // GetMatches devuelve TODOS los partidos (Delegamos al Repo)
+// con el margen y las cuotas justas de cada mercado y la probabilidad del modelo Elo.
 func (s *Service) GetMatches(sport string) ([]Match, error) {
 	// NOTA: Ignoramos el filtro de sport por ahora para asegurar
 	// que veas partidos aunque no coincidan con 'lol'.
 	// Y lo más importante: ¡Ya no filtramos por fecha!
-	return s.repo.GetMatches()
+	matches, err := s.repo.GetMatches()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range matches {
+		matches[i].Pricing = matches[i].PriceMarket()
+		for j := range matches[i].Lines {
+			matches[i].Lines[j].Pricing = matches[i].Lines[j].PriceMarket()
+		}
+	}
+
+	// Probabilidad del modelo Elo; si falla, el listado se sirve igual sin ella
+	if err := s.attachModelPrices(matches); err != nil {
+		log.Println("⚠️  [MARKET] No se pudieron calcular las probabilidades Elo:", err)
+	}
+	return matches, nil
 }
 
 // GetAvailableMatches es un alias por si tu Handler lo llama con este nombre
@@ -90,6 +280,21 @@ func (s *Service) GetAvailableMatches() ([]Match, error) {
 	return s.repo.GetMatches()
 }
 
+// sportKeyFromLeague infiere el deporte a partir del nombre de la liga
+func sportKeyFromLeague(league string) string {
+	leagueLower := strings.ToLower(league)
+	if strings.Contains(leagueLower, "lol") || strings.Contains(leagueLower, "league of legends") || strings.Contains(leagueLower, "lck") || strings.Contains(leagueLower, "lpl") {
+		return "lol"
+	} else if strings.Contains(leagueLower, "dota") {
+		return "dota2"
+	} else if strings.Contains(leagueLower, "cs2") || strings.Contains(leagueLower, "counter-strike") {
+		return "cs2"
+	} else if strings.Contains(leagueLower, "valorant") {
+		return "valorant"
+	}
+	return "esports"
+}
+
 // Helper para convertir int64 a string
 func filterNumericID(id int64) string {
 	return fmt.Sprintf("%d", id)
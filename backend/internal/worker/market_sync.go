@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/xnzperez/sports-analytics-backend/internal/market"
+)
+
+const (
+	// defaultSyncInterval es la frecuencia de sincronización si no se configura MARKET_SYNC_INTERVAL_MINUTES
+	defaultSyncInterval = 15 * time.Minute
+	// initialBackoff es la primera espera tras un error del proveedor; se duplica en cada fallo
+	initialBackoff = 30 * time.Second
+)
+
+// StartMarketSync sincroniza los mercados en segundo plano cada MARKET_SYNC_INTERVAL_MINUTES.
+// Si el proveedor falla reintenta con backoff exponencial (30s, 1m, 2m...) sin superar el intervalo.
+// Con MARKET_SYNC_INTERVAL_MINUTES=0 la sincronización automática queda desactivada.
+func StartMarketSync(marketService *market.Service) {
+	interval := syncInterval()
+	if interval == 0 {
+		fmt.Println("⏸️  [SYNC] Sincronización automática desactivada")
+		return
+	}
+
+	go func() {
+		fmt.Printf("🔄 [SYNC] Sincronización de mercados cada %s\n", interval)
+		failures := 0
+
+		for {
+			run, err := marketService.SyncEsports(market.SyncTriggerScheduler)
+
+			wait := interval
+			if err != nil {
+				failures++
+				wait = backoff(failures, interval)
+				fmt.Printf("❌ [SYNC] Error sincronizando (intento %d), reintento en %s: %v\n", failures, wait, err)
+			} else {
+				failures = 0
+				fmt.Printf("✅ [SYNC] %s: %d vistos, %d nuevos, %d actualizados, %d omitidos, %d retirados\n",
+					run.Mode, run.EventsSeen, run.Inserted, run.Updated, run.Skipped, run.Removed)
+			}
+
+			time.Sleep(wait)
+		}
+	}()
+}
+
+// backoff calcula la espera tras n fallos consecutivos, con el intervalo normal como máximo
+func backoff(failures int, max time.Duration) time.Duration {
+	wait := initialBackoff
+	for i := 1; i < failures && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		return max
+	}
+	return wait
+}
+
+// syncInterval lee el intervalo de sincronización desde el entorno
+func syncInterval() time.Duration {
+	if raw := os.Getenv("MARKET_SYNC_INTERVAL_MINUTES"); raw != "" {
+		if minutes, err := strconv.Atoi(raw); err == nil && minutes >= 0 {
+			return time.Duration(minutes) * time.Minute
+		}
+	}
+	return defaultSyncInterval
+}
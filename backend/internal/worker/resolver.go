@@ -2,33 +2,121 @@ package worker
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/xnzperez/sports-analytics-backend/internal/betting"
+	"github.com/xnzperez/sports-analytics-backend/internal/market"
 )
 
 // Estructura auxiliar para leer el JSON de details
 type BetDetails struct {
 	MatchID   string `json:"match_id"`
-	Selection string `json:"selection"` // "HOME" o "AWAY"
+	Selection string `json:"selection"` // "HOME", "AWAY" o "DRAW"
 	TeamName  string `json:"team_name"`
 }
 
-func StartScheduler(service *betting.Service) {
-	// Ejecutar cada 10 segundos
-	ticker := time.NewTicker(10 * time.Second)
+// StartScheduler arranca el liquidador automático.
+// Cada 10 segundos liquida los partidos que tienen un resultado confirmado y cada
+// 5 minutos descarga resultados del proveedor. Con DEMO_MODE=true, además simula
+// el resultado de los partidos con apuestas pendientes (solo para demostraciones).
+func StartScheduler(bettingService *betting.Service, marketService *market.Service) {
+	settleTicker := time.NewTicker(10 * time.Second)
+	resultsTicker := time.NewTicker(5 * time.Minute)
+	demoMode := os.Getenv("DEMO_MODE") == "true"
 
 	go func() {
-		fmt.Println("🤖 [WORKER] Auto-Resolver: Iniciado. Buscando apuestas para liquidar...")
-		for range ticker.C {
-			processPendingBets(service)
+		fmt.Println("🤖 [WORKER] Auto-Resolver: Iniciado. Buscando resultados para liquidar...")
+		if demoMode {
+			fmt.Println("🎲 [WORKER] DEMO_MODE activo: los resultados se simularán")
+		}
+
+		for {
+			select {
+			case <-settleTicker.C:
+				captureClosingLines(bettingService, marketService)
+				if demoMode {
+					simulatePendingMatches(bettingService, marketService)
+				}
+				settleConfirmedResults(bettingService, marketService)
+			case <-resultsTicker.C:
+				syncProviderResults(marketService)
+			}
 		}
 	}()
 }
 
-func processPendingBets(service *betting.Service) {
-	bets, err := service.GetPendingBets()
+// settleConfirmedResults liquida las apuestas de cada partido con resultado confirmado
+func settleConfirmedResults(bettingService *betting.Service, marketService *market.Service) {
+	results, err := marketService.GetUnsettledResults()
+	if err != nil {
+		fmt.Println("❌ [WORKER] Error buscando resultados:", err)
+		return
+	}
+
+	for _, result := range results {
+		// El servicio liquida cada selección y consolida las apuestas padre
+		if err := bettingService.SettleMatch(&result); err != nil {
+			// Sin marcador o sin mapas el resultado queda abierto: se puede volver a
+			// registrar completo y el siguiente ciclo liquidará lo que falte
+			if errors.Is(err, betting.ErrIncompleteResult) {
+				fmt.Printf("⚠️  [WORKER] Resultado incompleto del partido %s: %v\n", result.MatchID, err)
+				continue
+			}
+			fmt.Printf("❌ [WORKER] Error liquidando partido %s: %v\n", result.MatchID, err)
+			continue
+		}
+
+		if err := marketService.MarkResultSettled(result.ID); err != nil {
+			fmt.Printf("❌ [WORKER] Error marcando resultado %s: %v\n", result.ID, err)
+			continue
+		}
+
+		fmt.Printf("💰 [WORKER] Partido %s liquidado. Ganador: %s (%d-%d, fuente: %s)\n",
+			result.MatchID, result.Winner, result.HomeScore, result.AwayScore, result.Source)
+
+		// El resultado ya es definitivo: actualizar el Elo de los equipos
+		if err := marketService.ApplyResultRating(&result); err != nil {
+			fmt.Printf("⚠️  [WORKER] Error actualizando ratings del partido %s: %v\n", result.MatchID, err)
+		}
+	}
+}
+
+// captureClosingLines congela el precio de cierre de los partidos que comenzaron
+// y lo asigna a las apuestas hechas en ellos (base del CLV)
+func captureClosingLines(bettingService *betting.Service, marketService *market.Service) {
+	matches, err := marketService.CaptureClosingOdds()
+	if err != nil {
+		fmt.Println("❌ [WORKER] Error capturando cuotas de cierre:", err)
+		return
+	}
+
+	for i := range matches {
+		if err := bettingService.AttachClosingOdds(&matches[i]); err != nil {
+			fmt.Printf("❌ [WORKER] Error asignando cierre del partido %s: %v\n", matches[i].ID, err)
+		}
+	}
+}
+
+// syncProviderResults descarga los resultados oficiales del proveedor
+func syncProviderResults(marketService *market.Service) {
+	count, err := marketService.SyncResults()
+	if err != nil {
+		fmt.Println("❌ [WORKER] Error descargando resultados:", err)
+		return
+	}
+	if count > 0 {
+		fmt.Printf("📥 [WORKER] %d resultados recibidos del proveedor\n", count)
+	}
+}
+
+// simulatePendingMatches registra un resultado simulado para cada partido con apuestas
+// pendientes que aún no tenga resultado. Solo se usa en DEMO_MODE.
+func simulatePendingMatches(bettingService *betting.Service, marketService *market.Service) {
+	bets, err := bettingService.GetPendingBets()
 	if err != nil {
 		fmt.Println("❌ [WORKER] Error buscando apuestas:", err)
 		return
@@ -38,47 +126,119 @@ func processPendingBets(service *betting.Service) {
 		return
 	}
 
-	fmt.Printf("🔍 [WORKER] Analizando %d apuestas pendientes...\n", len(bets))
-
+	// 1. Agrupar por partido: cada selección (leg) se liquida con el resultado de su partido
+	matchIDs := make(map[uuid.UUID]bool)
 	for _, bet := range bets {
+		if len(bet.Legs) > 0 {
+			for _, leg := range bet.Legs {
+				if leg.Status == betting.StatusPending {
+					matchIDs[leg.MatchID] = true
+				}
+			}
+			continue
+		}
+
+		// Apuestas antiguas: el partido solo está en details
 		var details BetDetails
 		if err := json.Unmarshal([]byte(bet.Details), &details); err != nil {
 			continue
 		}
-
-		// 1. Simulamos quién ganó el partido (HOME o AWAY)
-		matchWinner := simulateWinner(bet.ID.String())
-
-		fmt.Printf("🎲 [SIMULACIÓN] Partido %s finalizado. Ganador del Match: %s\n", details.MatchID, matchWinner)
-
-		// 2. LÓGICA DE CORRECCIÓN: Comparamos selección vs ganador
-		// Aquí traducimos "HOME/AWAY" a "WON/LOST"
-		betOutcome := "LOST" // Por defecto perdió
-
-		if details.Selection == matchWinner {
-			betOutcome = "WON" // Si coinciden, ganó
+		if matchID, err := uuid.Parse(details.MatchID); err == nil {
+			matchIDs[matchID] = true
 		}
+	}
 
-		// 3. Enviamos el estado CORRECTO a la base de datos
-		err := service.ResolveBet(bet.ID.String(), betOutcome)
+	for matchID := range matchIDs {
+		// Ya tiene resultado (real o simulado): no lo pisamos
+		if _, err := marketService.GetResult(matchID); err == nil {
+			continue
+		}
 
-		if err != nil {
-			fmt.Printf("❌ [WORKER] Error resolviendo apuesta %s: %v\n", bet.ID, err)
-		} else {
-			fmt.Printf("💰 [WORKER] Apuesta %s liquidada. Usuario apostó %s -> Resultado: %s\n",
-				bet.ID, details.Selection, betOutcome)
+		// 2. Simulamos quién ganó el partido (HOME, AWAY o DRAW si el mercado es a tres vías)
+		// y el marcador en mapas
+		threeWay := false
+		if match, err := marketService.GetMatch(matchID); err == nil {
+			threeWay = match.ThreeWay()
+		}
+		winner := simulateWinner(matchID.String(), threeWay)
+		homeScore, awayScore := simulateScore(matchID.String(), winner)
+		result := &market.MatchResult{
+			MatchID:   matchID,
+			Winner:    winner,
+			HomeScore: homeScore,
+			AwayScore: awayScore,
+			Periods:   simulatePeriods(winner, homeScore+awayScore),
+			Source:    market.ResultSourceSimulation,
+			Confirmed: true,
+		}
+		if err := marketService.RecordResult(result); err != nil {
+			fmt.Printf("❌ [WORKER] No se pudo simular el partido %s: %v\n", matchID, err)
+			continue
 		}
+
+		fmt.Printf("🎲 [SIMULACIÓN] Partido %s finalizado. Ganador del Match: %s\n", matchID, result.Winner)
 	}
 }
 
-// simulateWinner decide aleatoriamente quién ganó (HOME o AWAY)
-func simulateWinner(seed string) string {
+// simulateWinner decide aleatoriamente quién ganó (HOME o AWAY; DRAW en mercados a tres vías)
+func simulateWinner(seed string, threeWay bool) string {
 	hash := 0
 	for _, char := range seed {
 		hash += int(char)
 	}
+	if threeWay && hash%3 == 0 {
+		return "DRAW"
+	}
 	if hash%2 == 0 {
 		return "HOME"
 	}
 	return "AWAY"
 }
+
+// simulateScore genera un marcador al mejor de 3 coherente con el ganador (2-0 o 2-1;
+// 1-1 en un empate), para poder liquidar hándicaps y totales en DEMO_MODE
+func simulateScore(seed string, winner string) (homeScore, awayScore int) {
+	if winner == "DRAW" {
+		return 1, 1
+	}
+
+	hash := 0
+	for _, char := range seed {
+		hash += int(char)
+	}
+	loserMaps := (hash / 2) % 2
+
+	if winner == "HOME" {
+		return 2, loserMaps
+	}
+	return loserMaps, 2
+}
+
+// simulatePeriods genera el resultado de cada mapa: el ganador se lleva el primero y el
+// último, y en un 2-1 pierde el segundo (en un empate 1-1, HOME gana el primero).
+// El marcador de cada mapa es siempre 13-7.
+func simulatePeriods(winner string, maps int) []market.PeriodResult {
+	drawn := winner == "DRAW"
+	loser := "AWAY"
+	switch winner {
+	case "AWAY":
+		loser = "HOME"
+	case "DRAW":
+		winner = "HOME"
+	}
+
+	periods := make([]market.PeriodResult, 0, maps)
+	for number := 1; number <= maps; number++ {
+		mapWinner := winner
+		if number == 2 && (maps == 3 || drawn) {
+			mapWinner = loser
+		}
+
+		period := market.PeriodResult{Period: number, Winner: mapWinner, HomeScore: 13, AwayScore: 7}
+		if mapWinner == "AWAY" {
+			period.HomeScore, period.AwayScore = 7, 13
+		}
+		periods = append(periods, period)
+	}
+	return periods
+}